@@ -6,20 +6,37 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/pwnholic/comdown/internal"
+	"github.com/pwnholic/comdown/internal/clients"
+	"github.com/pwnholic/comdown/internal/exports"
 )
 
 type Flag struct {
-	MaxChapter    int
-	MinChapter    int
+	// Chapters selects chapters by number, from -c or -min, -max and -s.
+	Chapters      clients.ChapterSelection
 	URL           string
 	URLs          []string // New field to store multiple URLs
-	Single        int
 	MaxConcurrent int
 	MergeSize     int
 	EnhanceImage  bool
 	BatchFile     *string // New field for batch file path
+	Formats       []exports.Format
+	Direction     string
+	SliceHeight   int
+	Layout        exports.PageLayout
+	// ImageMode overrides how images are converted for every format. The
+	// zero value keeps the per-format default.
+	ImageMode clients.ImageMode
+	// SaveAlias records the new domain of a site that redirects in
+	// config.json.
+	SaveAlias bool
+	// Duplicates overrides the duplicate policy of the site config. Nil
+	// keeps the site's own.
+	Duplicates clients.DuplicatePolicy
+	// Language overrides the language tag of the site config.
+	Language string
 }
 
 func parseFlag() *Flag {
@@ -27,12 +44,24 @@ func parseFlag() *Flag {
 	flag.BoolVar(help, "help", false, "Alias for -h")
 	url := flag.String("u", "", "Target URL (e.g. https://komikindo.id/one-piece)")
 	batchFile := flag.String("b", "", "File with list of URLs")
-	minChapter := flag.Int("min", 0, "Start chapter (for range)")
-	maxChapter := flag.Int("max", 0, "End chapter (for range)")
-	isSingle := flag.Int("s", 0, "Download specific chapter (overrides range)")
+	chapters := flag.String("c", "", "Chapters by number, e.g. 1-10,15,20.5,100-,latest:5")
+	minChapter := flag.String("min", "", "Start chapter number (for range)")
+	maxChapter := flag.String("max", "", "End chapter number (for range)")
+	isSingle := flag.String("s", "", "Download specific chapter number (overrides range)")
 	maxConcurrent := flag.Int("x", 16, "Max goroutines (default 10)")
 	mergeSize := flag.Int("M", 0, "Merge every N chapters into one PDF")
 	enhance := flag.Bool("e", false, "Enhance image quality (slower)")
+	format := flag.String("f", "pdf", "Output formats, comma separated: "+strings.Join(exports.FormatNames(), ", "))
+	direction := flag.String("d", exports.DirectionLTR, "EPUB page progression direction: ltr or rtl")
+	sliceHeight := flag.Int("slice", 0, "Re-slice long strips into pages N pixels tall (0 keeps original pages)")
+	layoutMode := flag.String("layout", exports.LayoutNative, "PDF page layout: native, fit or fit-width")
+	pageSize := flag.String("page", "a4", "PDF page size for fit layouts: a4, a5, b5, letter or WxH in mm")
+	margin := flag.Float64("margin", 0, "PDF page margin in mm for fit layouts")
+	background := flag.String("bg", "ffffff", "PDF page background colour for fit layouts (hex)")
+	duplicates := flag.String("dup", "", "Duplicate chapters: group:NAME, newest, most-pages, first, last or keep-all, comma separated (default from config, else newest)")
+	saveAlias := flag.Bool("save-alias", false, "Add the new domain of a site that redirects to its aliases in config.json")
+	language := flag.String("lang", "", "Language tag written into EPUB and CBZ metadata, e.g. id (default from config, else en)")
+	imageMode := flag.String("img", "", "Image mode: original, lossless or jpeg:<quality> (default jpeg:100 for pdf/epub, original for cbz/dir)")
 
 	flag.Parse()
 
@@ -43,7 +72,17 @@ func parseFlag() *Flag {
 		fmt.Println("\nExamples:")
 		fmt.Println("  Download single chapter: -u <URL> -s 42 -e")
 		fmt.Println("  Download range with enhancement: -u <URL> -min 10 -max 20 -e")
+		fmt.Println("  Pick chapters by number: -u <URL> -c 1-10,15,20.5,100-,latest:5")
 		fmt.Println("  Batch output without enhancement: -u <URL> -min 1 -max 50 -M 10")
+		fmt.Println("  Download range as CBZ archives: -u <URL> -min 1 -max 10 -f cbz")
+		fmt.Println("  Write PDF, CBZ and loose images in one pass: -u <URL> -min 1 -max 10 -f pdf,cbz,dir")
+		fmt.Println("  Merge right-to-left EPUB volumes: -u <URL> -min 1 -max 50 -M 10 -f epub -d rtl")
+		fmt.Println("  Re-slice webtoon strips into 2000px pages: -u <URL> -min 1 -max 10 -slice 2000")
+		fmt.Println("  Printable A4 PDFs with 10mm margins: -u <URL> -s 42 -layout fit -page a4 -margin 10")
+		fmt.Println("  Keep PNG line art lossless: -u <URL> -min 1 -max 10 -img lossless")
+		fmt.Println("  Shrink every page to JPEG quality 75: -u <URL> -min 1 -max 10 -img jpeg:75")
+		fmt.Println("  Prefer one group's release of duplicate chapters: -u <URL> -min 1 -max 10 -dup group:TeamX,newest")
+		fmt.Println("  Follow a site to its new domain: -u <URL> -s 42 -save-alias")
 		fmt.Println("  Process multiple URLs from file: -batch urls.txt -min 1 -max 10")
 		os.Exit(0)
 	}
@@ -88,13 +127,26 @@ func parseFlag() *Flag {
 		}
 	}
 
-	if *isSingle > 0 && (*minChapter > 0 || *maxChapter > 0) {
+	if *isSingle != "" && (*minChapter != "" || *maxChapter != "") {
 		internal.WarningLog("-s takes precedence over range flags")
 		os.Exit(1)
 	}
 
-	if *minChapter > *maxChapter && *isSingle == 0 {
-		internal.ErrorLog("-min must be <= max")
+	if *chapters != "" && (*isSingle != "" || *minChapter != "" || *maxChapter != "") {
+		internal.ErrorLog("Use either -c or -s/-min/-max, not both\n")
+		os.Exit(1)
+	}
+
+	chapterExpr := *chapters
+	switch {
+	case *isSingle != "":
+		chapterExpr = *isSingle
+	case *minChapter != "" || *maxChapter != "":
+		chapterExpr = *minChapter + "-" + *maxChapter
+	}
+	selection, err := clients.ParseChapterSelection(chapterExpr)
+	if err != nil {
+		internal.ErrorLog("%s\n", err.Error())
 		os.Exit(1)
 	}
 
@@ -103,20 +155,98 @@ func parseFlag() *Flag {
 		os.Exit(1)
 	}
 
+	if *sliceHeight < 0 {
+		internal.ErrorLog("Slice height (-slice) must be >= 0 (0 disables re-slicing)")
+		os.Exit(1)
+	}
+
+	layout, err := exports.ParsePageLayout(*layoutMode, *pageSize, *margin, *background)
+	if err != nil {
+		internal.ErrorLog("%s\n", err.Error())
+		os.Exit(1)
+	}
+
 	if *mergeSize < 0 {
 		internal.ErrorLog("Merge size must be >= 0 (0 disables batching)")
 		os.Exit(1)
 	}
 
+	formats, err := parseFormats(*format)
+	if err != nil {
+		internal.ErrorLog("%s\n", err.Error())
+		os.Exit(1)
+	}
+
+	var mode clients.ImageMode
+	if *imageMode != "" {
+		if mode, err = clients.ParseImageMode(*imageMode); err != nil {
+			internal.ErrorLog("%s\n", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	var duplicatePolicy clients.DuplicatePolicy
+	if *duplicates != "" {
+		if duplicatePolicy, err = clients.ParseDuplicatePolicy(*duplicates); err != nil {
+			internal.ErrorLog("%s\n", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if *direction != exports.DirectionLTR && *direction != exports.DirectionRTL {
+		internal.ErrorLog("Page direction (-d) must be ltr or rtl\n")
+		os.Exit(1)
+	}
+
 	return &Flag{
-		MaxChapter:    *maxChapter,
-		MinChapter:    *minChapter,
+		Chapters:      selection,
 		URL:           *url,
 		URLs:          urls,
 		MaxConcurrent: *maxConcurrent,
-		Single:        *isSingle,
 		MergeSize:     *mergeSize,
 		EnhanceImage:  *enhance,
 		BatchFile:     batchFile,
+		Formats:       formats,
+		Direction:     *direction,
+		SliceHeight:   *sliceHeight,
+		Layout:        layout,
+		ImageMode:     mode,
+		SaveAlias:     *saveAlias,
+		Duplicates:    duplicatePolicy,
+		Language:      strings.TrimSpace(*language),
+	}
+}
+
+// parseFormats resolves a comma separated list such as "pdf,cbz" into the
+// registered export formats, ignoring repeats.
+func parseFormats(value string) ([]exports.Format, error) {
+	var formats []exports.Format
+	seen := make(map[string]bool)
+	for name := range strings.SplitSeq(value, ",") {
+		format, ok := exports.LookupFormat(name)
+		if !ok {
+			return nil, fmt.Errorf("unsupported output format (-f): %q, choose from %s",
+				strings.TrimSpace(name), strings.Join(exports.FormatNames(), ", "))
+		}
+		if seen[format.Name] {
+			continue
+		}
+		seen[format.Name] = true
+		formats = append(formats, format)
+	}
+	return formats, nil
+}
+
+// imageMode is the image conversion used for format: the -img mode when one
+// was given, otherwise the original bytes for archive formats and JPEG for
+// documents.
+func (f *Flag) imageMode(format exports.Format) clients.ImageMode {
+	switch {
+	case f.ImageMode.Kind != "":
+		return f.ImageMode
+	case format.Original:
+		return clients.ImageMode{Kind: clients.ImageOriginal}
+	default:
+		return clients.DefaultImageMode
 	}
 }
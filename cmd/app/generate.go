@@ -9,7 +9,6 @@ import (
 	"path"
 	"path/filepath"
 	"sort"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -23,25 +22,40 @@ import (
 
 type generateComic struct {
 	clients   clients.RequestBuilder
-	exporter  exports.DocumentExporter
 	flag      *Flag
-	pdfPool   sync.Pool
+	pools     map[string]*sync.Pool
 	mutex     sync.Mutex
 	fileCache sync.Map
 	ctx       context.Context
 }
 
 func NewGenerateComic(httpOpts *clients.HTTPClientOptions, flag *Flag) *generateComic {
-	return &generateComic{
-		clients:  *clients.NewRequestBuilder(httpOpts),
-		exporter: *exports.NewDocumentExporter(),
-		flag:     flag,
-		ctx:      context.Background(),
-		pdfPool: sync.Pool{
+	pools := make(map[string]*sync.Pool, len(flag.Formats))
+	for _, format := range flag.Formats {
+		mode := flag.imageMode(format)
+		opts := exports.Options{
+			Direction:   flag.Direction,
+			SliceHeight: flag.SliceHeight,
+			Layout:      flag.Layout,
+			Lossless:    mode.Kind != clients.ImageJPEG,
+			JPEGQuality: mode.Quality,
+		}
+		pools[format.Name] = &sync.Pool{
 			New: func() any {
-				return exports.NewPDFGenerator()
+				return format.NewExporter(opts)
 			},
-		},
+		}
+	}
+
+	builder := clients.NewRequestBuilder(httpOpts)
+	builder.SaveAliases = flag.SaveAlias
+	builder.Duplicates = flag.Duplicates
+
+	return &generateComic{
+		clients: *builder,
+		flag:    flag,
+		ctx:     context.Background(),
+		pools:   pools,
 	}
 }
 
@@ -64,15 +78,11 @@ func (gc *generateComic) processBatchComic() error {
 			case <-ctx.Done():
 				return ctx.Err()
 			default:
-				localFlag := &Flag{
-					URL:           url,
-					MaxChapter:    gc.flag.MaxChapter,
-					MinChapter:    gc.flag.MinChapter,
-					Single:        gc.flag.Single,
-					MaxConcurrent: gc.flag.MaxConcurrent,
-					MergeSize:     gc.flag.MergeSize,
-				}
-				if err := gc.processSingleComic(localFlag); err != nil {
+				// Copy every flag so new ones reach batch runs too.
+				localFlag := *gc.flag
+				localFlag.URL = url
+				localFlag.URLs = nil
+				if err := gc.processSingleComic(&localFlag); err != nil {
 					errChan <- fmt.Errorf("error processing %s: %w", url, err)
 					return err
 				}
@@ -109,7 +119,24 @@ func (gc *generateComic) processSingleComic(flag *Flag) error {
 	startTime := time.Now()
 	internal.InfoLog("Starting chapter processing with %d max workers\n", flag.MaxConcurrent)
 
-	folderName, err := getLastPathSegment(flag.URL)
+	scraper, err := gc.clients.Scraper(flag.URL)
+	if err != nil {
+		return err
+	}
+
+	// The chapter list comes first so the series details can be read off
+	// the page it fetched.
+	allLinks, err := gc.clients.ListChapters(scraper, flag.URL, flag.Chapters)
+	if err != nil {
+		return fmt.Errorf("error fetching links: %w", err)
+	}
+
+	info, err := scraper.SeriesInfo(flag.URL)
+	if err != nil {
+		internal.WarningLog("Could not read series info: %s\n", err.Error())
+	}
+
+	folderName, err := seriesFolderName("comics", info, flag.URL)
 	if err != nil {
 		internal.ErrorLog("Could not get path segment with error: %s\n", err.Error())
 		return err
@@ -119,65 +146,91 @@ func (gc *generateComic) processSingleComic(flag *Flag) error {
 	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
 		return fmt.Errorf("failed to create comic directory: %w", err)
 	}
-
 	internal.InfoLog("Creating New Directory [%s]\n", dir)
-	attr := gc.clients.Website.GetHTMLTagAttrFromURL(flag.URL)
-	if attr == nil {
-		return fmt.Errorf("HTML attribute not found or website unsupported")
-	}
 
-	comicMeta := clients.ComicMetadata{
-		MaxChapter:    flag.MaxChapter,
-		MinChapter:    flag.MinChapter,
-		URL:           flag.URL,
-		Single:        flag.Single,
-		ScraperConfig: *attr,
+	if err := writeSeriesDetails(dir, info); err != nil {
+		internal.WarningLog("Could not write series details: %s\n", err.Error())
 	}
 
-	allLinks, err := gc.clients.Request.CollectLinks(&comicMeta)
-	if err != nil {
-		return fmt.Errorf("error fetching links: %w", err)
+	seriesMeta := exports.Metadata{
+		Series:    folderName,
+		SourceURL: flag.URL,
+		Date:      startTime,
+		AltTitles: info.AltTitles,
+		Authors:   info.Authors,
+		Artists:   info.Artists,
+		Genres:    info.Genres,
+		Status:    info.Status,
+		Synopsis:  info.Synopsis,
+		Language:  info.Language,
+	}
+	if flag.Language != "" {
+		seriesMeta.Language = flag.Language
+	}
+	if info.Title != "" {
+		seriesMeta.Series = info.Title
 	}
 
 	internal.InfoLog("Processing %d chapters\n", len(allLinks))
-	results, err := gc.processChapterLinks(dir, allLinks, attr)
+	results, err := gc.processChapterLinks(dir, allLinks, scraper, seriesMeta)
 	if err != nil {
 		return err
 	}
 
 	if gc.flag.MergeSize > 0 {
-		if err := gc.processMergeChapter(results.batchLinks, folderName); err != nil {
+		seriesMeta.Cover = gc.collectCover(scraper, flag.URL, info.CoverURL)
+		if err := gc.processMergeChapter(scraper, results.batchLinks, folderName, seriesMeta); err != nil {
 			return err
 		}
 	}
 
 	internal.InfoLog("[SUMMARY] Processed %d chapters in %v\n", len(allLinks), time.Since(startTime))
-	internal.InfoLog("[SUMMARY] Generated %d PDF files\n", len(results.generatedFiles))
+	internal.InfoLog("[SUMMARY] Generated %s\n", describeOutputs(results.generatedFiles))
 	internal.InfoLog("[SUMMARY] Processed %d images in total\n", results.totalImages)
 	return nil
 }
 
+// collectCover downloads the series cover for the title page of merged
+// volumes. Covers are optional, so failures are only logged.
+func (gc *generateComic) collectCover(scraper clients.Scraper, seriesURL, coverURL string) []byte {
+	if coverURL == "" {
+		return nil
+	}
+
+	cover, err := scraper.FetchImage(seriesURL, coverURL)
+	if err != nil {
+		internal.WarningLog("Could not download cover [%s]: %s\n", coverURL, err.Error())
+		return nil
+	}
+	return cover
+}
+
 type processResults struct {
-	generatedFiles []string
-	batchLinks     map[string][]string
+	generatedFiles []chapterOutput
+	batchLinks     map[string]chapterPages
 	totalImages    int
 }
 
-func (gc *generateComic) processChapterLinks(comicDir string, allLinks []string, attr *clients.ScraperConfig) (*processResults, error) {
+func (gc *generateComic) processChapterLinks(
+	comicDir string,
+	allLinks []clients.Chapter,
+	scraper clients.Scraper,
+	seriesMeta exports.Metadata,
+) (*processResults, error) {
 	g, ctx := errgroup.WithContext(gc.ctx)
 	g.SetLimit(gc.flag.MaxConcurrent)
 
 	var results processResults
-	results.batchLinks = make(map[string][]string)
+	results.batchLinks = make(map[string]chapterPages)
 
-	for _, rawURL := range allLinks {
-		rawURL := rawURL
+	for order, link := range allLinks {
+		link := link
 		g.Go(func() error {
 			select {
 			case <-ctx.Done():
-				return errors.Join(ctx.Err(), fmt.Errorf("for this link %s", rawURL))
+				return errors.Join(ctx.Err(), fmt.Errorf("for this link %s", link.URL))
 			default:
-				return gc.processComicChapter(comicDir, rawURL, attr, &results)
+				return gc.processComicChapter(comicDir, order, link, scraper, seriesMeta, &results)
 			}
 		})
 	}
@@ -190,33 +243,46 @@ func (gc *generateComic) processChapterLinks(comicDir string, allLinks []string,
 }
 
 func (gc *generateComic) processComicChapter(
-	comicDir, rawURL string,
-	attr *clients.ScraperConfig,
+	comicDir string,
+	order int,
+	link clients.Chapter,
+	scraper clients.Scraper,
+	seriesMeta exports.Metadata,
 	results *processResults,
 ) error {
-	titleStr, err := gc.clients.Website.GetChapterNumber(rawURL)
-	if err != nil {
-		internal.ErrorLog("could not extract chapter number from URL: %s\n", rawURL)
-		return err
+	rawURL := link.URL
+	number, err := scraper.ChapterNumber(link)
+	numbered := err == nil
+	titleStr := number.String()
+	if !numbered {
+		// Extras, specials and announcements have no number, so they are
+		// named after their title or URL.
+		titleStr = unnumberedName(link)
+		if titleStr == "" {
+			internal.WarningLog("Skipping %s, it has no chapter number or name: %s\n", rawURL, err.Error())
+			return nil
+		}
 	}
-
-	outputFilename := filepath.Join(comicDir, fmt.Sprintf("%s.pdf", titleStr))
-
-	if isFileExists(outputFilename, &gc.fileCache) {
-		internal.InfoLog("File already exists, skipping: %s\n", outputFilename)
-		return nil
+	if link.Variant != "" {
+		// Copies of a chapter kept side by side need names of their own.
+		titleStr = fmt.Sprintf("%s [%s]", titleStr, sanitizeFileName(link.Variant))
 	}
 
-	comicMeta := clients.ComicMetadata{
-		URL:           rawURL,
-		ScraperConfig: *attr,
+	outputs := gc.chapterOutputs(filepath.Join(comicDir, titleStr))
+	if len(outputs) == 0 {
+		return nil
 	}
 
-	imgFromPage, err := gc.clients.Request.CollectImgTagsLink(&comicMeta)
+	imgFromPage, err := scraper.Pages(rawURL)
 	if err != nil {
 		return fmt.Errorf("error fetching page links: %w", err)
 	}
 	if len(imgFromPage) == 0 {
+		if !numbered {
+			// Announcements are listed like chapters but carry no pages.
+			internal.WarningLog("Skipping %s, it has no chapter number and no images\n", rawURL)
+			return nil
+		}
 		return fmt.Errorf("no images found for chapter: %s", rawURL)
 	}
 
@@ -226,84 +292,190 @@ func (gc *generateComic) processComicChapter(
 
 	if gc.flag.MergeSize > 0 {
 		gc.mutex.Lock()
-		results.batchLinks[titleStr] = imgFromPage
+		results.batchLinks[titleStr] = chapterPages{title: titleStr, name: link.Title, extra: !numbered, url: rawURL, order: order, images: imgFromPage}
 		gc.mutex.Unlock()
 		return nil
 	}
 
-	if err := gc.processChapterImages(imgFromPage, outputFilename); err != nil {
+	meta := seriesMeta
+	if numbered {
+		meta.Chapter = number.String()
+		meta.Volume = number.Volume
+	}
+	meta.SourceURL = rawURL
+	meta.ChapterTitle = link.Title
+	meta.Released = link.Date
+	meta.Scanlator = link.Scanlator
+	if err := gc.processChapterImages(scraper, []chapterPages{{url: rawURL, images: imgFromPage}}, outputs, meta); err != nil {
 		return err
 	}
 
 	gc.mutex.Lock()
-	results.generatedFiles = append(results.generatedFiles, outputFilename)
+	results.generatedFiles = append(results.generatedFiles, outputs...)
 	gc.mutex.Unlock()
 	return nil
 }
 
-func (gc *generateComic) processChapterImages(imgFromPage []string, outputFilename string) error {
-	pdfGen := gc.pdfPool.Get().(*exports.PDFGenerator)
-	defer func() {
-		pdfGen.Reset()
-		gc.pdfPool.Put(pdfGen)
-	}()
+// chapterPages is one chapter's share of an output file. The title (the
+// chapter number, or the file name of an extra without one), the name the
+// site gives the chapter and the chapter's position in the ordered chapter
+// list are only set when several chapters are merged and each one gets a
+// bookmark, the URL is the chapter page its images are fetched for.
+type chapterPages struct {
+	title  string
+	name   string
+	extra  bool
+	url    string
+	order  int
+	images []string
+}
+
+// heading is the bookmark and contents entry of the chapter.
+func (ch chapterPages) heading() string {
+	if ch.extra {
+		if ch.name != "" {
+			return ch.name
+		}
+		return ch.title
+	}
+	if ch.name != "" && !strings.EqualFold(ch.name, "Chapter "+ch.title) {
+		return fmt.Sprintf("Chapter %s: %s", ch.title, ch.name)
+	}
+	return fmt.Sprintf("Chapter %s", ch.title)
+}
+
+type chapterOutput struct {
+	format exports.Format
+	path   string
+}
+
+// describeOutputs counts the files written per format, e.g.
+// "6 files (3 pdf, 3 cbz)".
+func describeOutputs(outputs []chapterOutput) string {
+	counts := make(map[string]int)
+	var names []string
+	for _, out := range outputs {
+		if counts[out.format.Name] == 0 {
+			names = append(names, out.format.Name)
+		}
+		counts[out.format.Name]++
+	}
+	if len(names) == 0 {
+		return "0 files"
+	}
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%d %s", counts[name], name)
+	}
+	return fmt.Sprintf("%d files (%s)", len(outputs), strings.Join(parts, ", "))
+}
 
-	if len(imgFromPage) < 1 {
+// chapterOutputs lists the files to write for baseName in every selected
+// format, leaving out the ones already on disk.
+func (gc *generateComic) chapterOutputs(baseName string) []chapterOutput {
+	var outputs []chapterOutput
+	for _, format := range gc.flag.Formats {
+		outputPath := format.OutputName(baseName)
+		if isFileExists(outputPath, &gc.fileCache) {
+			internal.InfoLog("File already exists, skipping: %s\n", outputPath)
+			continue
+		}
+		outputs = append(outputs, chapterOutput{format: format, path: outputPath})
+	}
+	return outputs
+}
+
+// processChapterImages downloads every image once and hands it to the exporter
+// of each output, converted for the image mode of its format. Each mode is
+// converted only once per image.
+func (gc *generateComic) processChapterImages(scraper clients.Scraper, chapters []chapterPages, outputs []chapterOutput, meta exports.Metadata) error {
+	totalImages := 0
+	for _, ch := range chapters {
+		totalImages += len(ch.images)
+	}
+	if totalImages < 1 {
 		internal.ErrorLog("image form page should not be 0\n")
 		return nil
 	}
 
-	for _, imgURL := range imgFromPage {
-		imageData, err := gc.clients.Request.CollectImage(imgURL, gc.flag.EnhanceImage)
-		if imageData == nil {
-			internal.ErrorLog("This link [%s] has empty image\n", imgURL)
-			continue
+	exporters := make([]exports.Exporter, len(outputs))
+	modes := make([]clients.ImageMode, len(outputs))
+	for i, out := range outputs {
+		exporters[i] = gc.pools[out.format.Name].Get().(exports.Exporter)
+		exporters[i].SetMetadata(meta)
+		modes[i] = gc.flag.imageMode(out.format)
+	}
+	defer func() {
+		for i, out := range outputs {
+			exporters[i].Reset()
+			gc.pools[out.format.Name].Put(exporters[i])
 		}
+	}()
 
-		if err != nil {
-			internal.ErrorLog("could not get image byte data with error :%s\n", err.Error())
-			return err
+	for _, ch := range chapters {
+		if ch.title != "" {
+			for _, exporter := range exporters {
+				exporter.AddChapter(ch.heading())
+			}
 		}
 
-		if err := pdfGen.AddImageToPDF(imageData, outputFilename, imgURL); err != nil {
-			internal.ErrorLog("Error adding image to PDF for this [%s] link with error [%s] \n", imgURL, err.Error())
-			return err
+		for _, imgURL := range ch.images {
+			rawData, err := scraper.FetchImage(ch.url, imgURL)
+			if err != nil {
+				internal.ErrorLog("could not get image byte data with error :%s\n", err.Error())
+				return err
+			}
+
+			if rawData == nil {
+				internal.ErrorLog("This link [%s] has empty image\n", imgURL)
+				continue
+			}
+
+			converted := make(map[clients.ImageMode][]byte, 1)
+			for i, out := range outputs {
+				imageData, ok := converted[modes[i]]
+				if !ok {
+					imageData, err = gc.clients.Request.ConvertImage(rawData, modes[i], gc.flag.EnhanceImage)
+					if err != nil {
+						internal.ErrorLog("could not convert image [%s] with error :%s\n", imgURL, err.Error())
+						return err
+					}
+					converted[modes[i]] = imageData
+				}
+				if err := exporters[i].AddImage(imageData, out.path, imgURL); err != nil {
+					internal.ErrorLog("Error adding image to %s for this [%s] link with error [%s] \n", out.format.Name, imgURL, err.Error())
+					return err
+				}
+			}
 		}
 	}
 
-	if err := pdfGen.SavePDF(outputFilename); err != nil {
-		return err
+	for i, out := range outputs {
+		if err := exporters[i].Save(out.path); err != nil {
+			return err
+		}
+		internal.SuccessLog("Saved to %s\n", out.path)
 	}
-
-	internal.SuccessLog("Saved to %s\n", outputFilename)
 	return nil
 }
 
-func (gc *generateComic) processMergeChapter(batchLinks map[string][]string, comicDir string) error {
+func (gc *generateComic) processMergeChapter(scraper clients.Scraper, batchLinks map[string]chapterPages, comicDir string, seriesMeta exports.Metadata) error {
 	internal.InfoLog("Starting batch processing with size %d\n", gc.flag.MergeSize)
 	if gc.flag.MergeSize <= 0 {
 		return nil
 	}
 
 	// Convert map to slice of chapters for sorting
-	type chapter struct {
-		title  string
-		images []string
-	}
-	var chapters []chapter
+	var chapters []chapterPages
 
-	for title, images := range batchLinks {
-		chapters = append(chapters, chapter{title, images})
+	for _, chapter := range batchLinks {
+		chapters = append(chapters, chapter)
 	}
 
-	// Sort chapters by their title (assuming it's a number)
+	// Put the chapters back in the order of the chapter list, which the
+	// scraper already sorted.
 	sort.Slice(chapters, func(i, j int) bool {
-		numI, errI := strconv.ParseFloat(chapters[i].title, 64)
-		numJ, errJ := strconv.ParseFloat(chapters[j].title, 64)
-		if errI != nil || errJ != nil {
-			return chapters[i].title < chapters[j].title
-		}
-		return numI < numJ
+		return chapters[i].order < chapters[j].order
 	})
 
 	// Process in batches
@@ -318,7 +490,6 @@ func (gc *generateComic) processMergeChapter(batchLinks map[string][]string, com
 			case <-ctx.Done():
 				return ctx.Err()
 			default:
-				var images []string
 				startTitle := batch[0].title
 				endTitle := batch[len(batch)-1].title
 				title := startTitle
@@ -327,12 +498,13 @@ func (gc *generateComic) processMergeChapter(batchLinks map[string][]string, com
 					title = fmt.Sprintf("%s-%s", startTitle, endTitle)
 				}
 
-				for _, ch := range batch {
-					images = append(images, ch.images...)
+				outputs := gc.chapterOutputs(filepath.Join("comics", comicDir, title))
+				if len(outputs) == 0 {
+					return nil
 				}
-
-				filename := filepath.Join("comics", comicDir, fmt.Sprintf("%s.pdf", title))
-				return gc.processChapterImages(images, filename)
+				meta := seriesMeta
+				meta.Chapter = title
+				return gc.processChapterImages(scraper, batch, outputs, meta)
 			}
 		})
 	}
@@ -340,28 +512,41 @@ func (gc *generateComic) processMergeChapter(batchLinks map[string][]string, com
 	return g.Wait()
 }
 
+// fileMagic holds the leading bytes every complete output file must start with.
+var fileMagic = map[string]string{
+	".pdf":  "%PDF-",
+	".cbz":  "PK\x03\x04",
+	".epub": "PK\x03\x04",
+}
+
 func isFileExists(filename string, cache *sync.Map) bool {
 	if val, ok := cache.Load(filename); ok {
 		return val.(bool)
 	}
 
 	info, err := os.Stat(filename)
-	if err != nil || info.IsDir() {
+	if err != nil {
 		cache.Store(filename, false)
 		return false
 	}
 
-	if strings.HasSuffix(strings.ToLower(filename), ".pdf") {
+	if info.IsDir() {
+		exists := exports.DirComplete(filename)
+		cache.Store(filename, exists)
+		return exists
+	}
+
+	if magic, ok := fileMagic[strings.ToLower(filepath.Ext(filename))]; ok {
 		file, err := os.Open(filename)
 		if err != nil {
 			cache.Store(filename, false)
 			return false
 		}
 		defer file.Close()
-		header := make([]byte, 5)
-		if _, err := file.Read(header); err != nil || string(header) != "%PDF-" {
+		header := make([]byte, len(magic))
+		if _, err := file.Read(header); err != nil || string(header) != magic {
 			_ = os.Remove(filename)
-			internal.WarningLog("Removed corrupt PDF: %s\n", filename)
+			internal.WarningLog("Removed corrupt file: %s\n", filename)
 			cache.Store(filename, false)
 			return false
 		}
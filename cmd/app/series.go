@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/pwnholic/comdown/internal"
+	"github.com/pwnholic/comdown/internal/clients"
+)
+
+// maxFolderNameLength keeps series folders well inside file name limits,
+// counted in runes.
+const maxFolderNameLength = 100
+
+// seriesFolderName names the series folder below root after the series
+// title, falling back to the last segment of the series URL when the site
+// config does not read one. A folder named after the URL that an earlier
+// download left behind is kept, so its chapters are not fetched again.
+func seriesFolderName(root string, info clients.SeriesInfo, rawURL string) (string, error) {
+	slug, err := getLastPathSegment(rawURL)
+	if err != nil {
+		return "", err
+	}
+	name := sanitizeFileName(info.Title)
+	if name == "" || name == slug {
+		return slug, nil
+	}
+	if isDir(filepath.Join(root, slug)) && !isDir(filepath.Join(root, name)) {
+		internal.InfoLog("Keeping existing folder %s for %s\n", slug, info.Title)
+		return slug, nil
+	}
+	return name, nil
+}
+
+func isDir(name string) bool {
+	info, err := os.Stat(name)
+	return err == nil && info.IsDir()
+}
+
+// sanitizeFileName drops the characters Windows and Unix file systems refuse
+// and the dots and spaces Windows trims from the ends of names.
+func sanitizeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return ' '
+		}
+		return r
+	}, name)
+	name = strings.Join(strings.Fields(name), " ")
+	if runes := []rune(name); len(runes) > maxFolderNameLength {
+		name = string(runes[:maxFolderNameLength])
+	}
+	return strings.Trim(name, ". ")
+}
+
+// seriesDetails is the details.json that the Mihon and Tachiyomi local source
+// reads next to the chapters of a series.
+type seriesDetails struct {
+	Title       string   `json:"title,omitempty"`
+	Author      string   `json:"author,omitempty"`
+	Artist      string   `json:"artist,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Genre       []string `json:"genre,omitempty"`
+	Status      string   `json:"status"`
+}
+
+// detailsStatus holds the status codes of details.json, "0" is unknown.
+var detailsStatus = map[string]string{
+	clients.StatusOngoing:   "1",
+	clients.StatusCompleted: "2",
+	clients.StatusCancelled: "5",
+	clients.StatusHiatus:    "6",
+}
+
+// writeSeriesDetails writes details.json into the series folder when the site
+// config reads any series details.
+func writeSeriesDetails(dir string, info clients.SeriesInfo) error {
+	details := seriesDetails{
+		Title:       info.Title,
+		Author:      strings.Join(info.Authors, ", "),
+		Artist:      strings.Join(info.Artists, ", "),
+		Description: info.Synopsis,
+		Genre:       info.Genres,
+		Status:      detailsStatus[info.Status],
+	}
+	if details.Status == "" {
+		details.Status = "0"
+	}
+	if details.Title == "" && details.Author == "" && details.Description == "" && len(details.Genre) == 0 {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(details, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode series details: %w", err)
+	}
+	return os.WriteFile(filepath.Join(dir, "details.json"), append(data, '\n'), 0o644)
+}
+
+// unnumberedName names the file of a chapter without a number after its
+// title, or the last segment of its URL when the listing gives none.
+func unnumberedName(link clients.Chapter) string {
+	if name := sanitizeFileName(link.Title); name != "" {
+		return name
+	}
+	segment, err := getLastPathSegment(link.URL)
+	if err != nil {
+		return ""
+	}
+	return sanitizeFileName(segment)
+}
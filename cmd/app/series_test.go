@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pwnholic/comdown/internal/clients"
+)
+
+func TestSeriesFolderName(t *testing.T) {
+	const seriesURL = "https://example.com/manga/one-piece/"
+	tests := []struct {
+		name     string
+		title    string
+		existing []string
+		want     string
+	}{
+		{"no title", "", nil, "one-piece"},
+		{"new series", "One Piece", nil, "One Piece"},
+		{"earlier slug folder", "One Piece", []string{"one-piece"}, "one-piece"},
+		{"both folders", "One Piece", []string{"one-piece", "One Piece"}, "One Piece"},
+		{"unsafe title", "Re:Zero / Part 2", nil, "Re Zero Part 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			for _, dir := range tt.existing {
+				if err := os.Mkdir(filepath.Join(root, dir), 0o755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := seriesFolderName(root, clients.SeriesInfo{Title: tt.title}, seriesURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("seriesFolderName = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnnumberedName(t *testing.T) {
+	tests := []struct {
+		link clients.Chapter
+		want string
+	}{
+		{clients.Chapter{URL: "https://example.com/manga/extra-story/", Title: "Side Story: Beach"}, "Side Story Beach"},
+		{clients.Chapter{URL: "https://example.com/manga/extra-story/"}, "extra-story"},
+		{clients.Chapter{URL: "https://example.com/"}, ""},
+	}
+	for _, tt := range tests {
+		if got := unnumberedName(tt.link); got != tt.want {
+			t.Errorf("unnumberedName(%q, %q) = %q, want %q", tt.link.URL, tt.link.Title, got, tt.want)
+		}
+	}
+}
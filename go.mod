@@ -2,15 +2,12 @@ module github.com/pwnholic/comdown
 
 go 1.24.1
 
-require github.com/signintech/gopdf v0.31.0
-
 require github.com/disintegration/imaging v1.6.2
 
 require (
 	github.com/PuerkitoBio/goquery v1.10.2
 	github.com/andybalholm/cascadia v1.3.3 // indirect
-	github.com/phpdave11/gofpdi v1.0.14 // indirect
-	github.com/pkg/errors v0.9.1 // indirect
+	github.com/ledongthuc/pdf v0.0.0-20220302134840-0c2507a12d80
 	golang.org/x/image v0.25.0
 	golang.org/x/net v0.37.0
 	golang.org/x/sync v0.12.0
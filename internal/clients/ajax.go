@@ -0,0 +1,165 @@
+package clients
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/pwnholic/comdown/internal"
+)
+
+// ChapterRequest describes a second request that returns the chapter list,
+// for sites whose series page loads it with AJAX, like Madara themes posting
+// to wp-admin/admin-ajax.php or {url}/ajax/chapters/. URL, Form values and
+// JSON are templates: {url} is the series URL, {origin} its scheme and host,
+// {page} the chapter list page number and {name} any value named in Values.
+// Method defaults to POST.
+type ChapterRequest struct {
+	Method string                   `json:"method"`
+	URL    string                   `json:"url"`
+	Form   map[string]string        `json:"form"`
+	JSON   string                   `json:"json"`
+	Values map[string]ValueSelector `json:"values"`
+}
+
+// ValueSelector pulls one value off a page: the text of the first element
+// Selector matches, or its Attr, narrowed to the first group of Pattern.
+// Without a selector the pattern runs over the raw page, which is how values
+// in inline scripts such as nonces are found.
+type ValueSelector struct {
+	Selector string `json:"selector"`
+	Attr     string `json:"attr"`
+	Pattern  string `json:"pattern"`
+}
+
+func (r ChapterRequest) enabled() bool {
+	return r.URL != ""
+}
+
+// fetchChapterRequest sends the chapter request for a list page and returns
+// the response to run the chapter selectors on.
+func (c *clientRequest) fetchChapterRequest(document *goquery.Document, metadata *ComicMetadata, page int) (*goquery.Document, error) {
+	chapterReq, seriesURL := metadata.ChapterRequest, metadata.URL
+	values, err := templateValues(document, chapterReq.Values, seriesURL, page)
+	if err != nil {
+		return nil, err
+	}
+
+	method := strings.ToUpper(chapterReq.Method)
+	if method == "" {
+		method = http.MethodPost
+	}
+	requestURL := expandTemplate(chapterReq.URL, values, nil)
+
+	request := c.pageRequest(metadata.ScraperConfig).
+		SetHeader("X-Requested-With", "XMLHttpRequest").
+		SetHeader("Referer", seriesURL)
+	switch {
+	case chapterReq.JSON != "":
+		request.SetHeader("Content-Type", "application/json").
+			SetBody(expandTemplate(chapterReq.JSON, values, jsonEscape))
+	case len(chapterReq.Form) > 0:
+		form := make(map[string]string, len(chapterReq.Form))
+		for key, value := range chapterReq.Form {
+			form[key] = expandTemplate(value, values, nil)
+		}
+		request.SetFormData(form)
+	}
+
+	response, err := request.Execute(method, requestURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch chapter list from %s: %w", requestURL, err)
+	}
+	defer response.Body.Close()
+
+	checkBlockStatus(response)
+
+	if response.StatusCode() != http.StatusOK {
+		return nil, fmt.Errorf("chapter list request %s %s returned status %d", method, requestURL, response.StatusCode())
+	}
+	internal.InfoLog("Loaded chapter list from %s %s\n", method, requestURL)
+
+	return parseHTMLResponse(response)
+}
+
+// templateValues collects the placeholders a chapter request can use.
+func templateValues(document *goquery.Document, selectors map[string]ValueSelector, seriesURL string, page int) (map[string]string, error) {
+	values := map[string]string{
+		"url":  strings.TrimSuffix(seriesURL, "/"),
+		"page": strconv.Itoa(page),
+	}
+	if parsed, err := url.Parse(seriesURL); err == nil {
+		values["origin"] = parsed.Scheme + "://" + parsed.Host
+	}
+
+	for name, selector := range selectors {
+		value, err := selector.extract(document)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s for the chapter request: %w", name, err)
+		}
+		values[name] = value
+	}
+	return values, nil
+}
+
+func (v ValueSelector) extract(document *goquery.Document) (string, error) {
+	var value string
+	if v.Selector != "" {
+		selection := document.Find(v.Selector).First()
+		if selection.Length() == 0 {
+			return "", fmt.Errorf("nothing matches %q", v.Selector)
+		}
+		if v.Attr != "" {
+			value = selection.AttrOr(v.Attr, "")
+		} else {
+			value = selection.Text()
+		}
+	} else {
+		html, err := document.Html()
+		if err != nil {
+			return "", err
+		}
+		value = html
+	}
+
+	if v.Pattern != "" {
+		re, err := regexp.Compile(v.Pattern)
+		if err != nil {
+			return "", fmt.Errorf("invalid pattern: %w", err)
+		}
+		match := re.FindStringSubmatch(value)
+		if len(match) < 2 {
+			return "", fmt.Errorf("pattern %q does not match", v.Pattern)
+		}
+		value = match[1]
+	}
+
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", fmt.Errorf("value for %q is empty", v.Selector)
+	}
+	return value, nil
+}
+
+// expandTemplate replaces every {name} in template with its value, passed
+// through escape when one is given.
+func expandTemplate(template string, values map[string]string, escape func(string) string) string {
+	pairs := make([]string, 0, 2*len(values))
+	for name, value := range values {
+		if escape != nil {
+			value = escape(value)
+		}
+		pairs = append(pairs, "{"+name+"}", value)
+	}
+	return strings.NewReplacer(pairs...).Replace(template)
+}
+
+func jsonEscape(value string) string {
+	quoted, _ := json.Marshal(value)
+	return string(quoted[1 : len(quoted)-1])
+}
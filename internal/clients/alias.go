@@ -0,0 +1,243 @@
+package clients
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/pwnholic/comdown/internal"
+	"golang.org/x/net/idna"
+)
+
+// regexAliasPrefix marks a hostname or alias that is a regular expression
+// rather than a name or glob.
+const regexAliasPrefix = "re:"
+
+// How well a host matches a hostname pattern, weakest first. An exact name
+// wins over a pattern so a broad glob does not shadow a site of its own.
+const (
+	hostNoMatch = iota
+	hostPatternMatch
+	hostExactMatch
+)
+
+// normalizeHost brings a hostname into the form hosts are compared in: lower
+// case ASCII, with IDN labels in punycode, no trailing dot and no "www."
+// prefix. Glob characters are left alone so patterns normalise the same way.
+func normalizeHost(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+	labels := strings.Split(host, ".")
+	for i, label := range labels {
+		if ascii, err := idna.ToASCII(label); err == nil {
+			labels[i] = ascii
+		}
+	}
+	return strings.TrimPrefix(strings.Join(labels, "."), "www.")
+}
+
+// matchHost compares a normalised host with one hostname pattern: a plain
+// name, a glob such as "komikcast*.com" or "re:" and a regular expression
+// that has to match the whole host, in its punycode or its Unicode form.
+func matchHost(pattern, host string) int {
+	if expr, ok := strings.CutPrefix(pattern, regexAliasPrefix); ok {
+		re, err := regexp.Compile(`^(?:` + expr + `)$`)
+		if err != nil {
+			internal.WarningLog("Invalid host pattern %q: %v\n", pattern, err)
+			return hostNoMatch
+		}
+		unicodeHost, _ := idna.ToUnicode(host)
+		if re.MatchString(host) || re.MatchString(unicodeHost) {
+			return hostPatternMatch
+		}
+		return hostNoMatch
+	}
+
+	pattern = normalizeHost(pattern)
+	if !strings.ContainsAny(pattern, "*?[") {
+		if pattern == host {
+			return hostExactMatch
+		}
+		return hostNoMatch
+	}
+	if ok, _ := path.Match(pattern, host); ok {
+		return hostPatternMatch
+	}
+	return hostNoMatch
+}
+
+// matchHost reports how well a normalised host matches the entry's hostname
+// and aliases.
+func (s ScraperConfig) matchHost(host string) int {
+	best := matchHost(s.Hostname, host)
+	for _, alias := range s.Aliases {
+		best = max(best, matchHost(alias, host))
+	}
+	return best
+}
+
+// movedHost returns the normalised host of finalURL when a request for
+// requestURL ended up on another domain, and "" otherwise.
+func movedHost(requestURL string, finalURL *url.URL) string {
+	requested, err := url.Parse(requestURL)
+	if err != nil || finalURL == nil {
+		return ""
+	}
+	from, to := normalizeHost(requested.Hostname()), normalizeHost(finalURL.Hostname())
+	if to == "" || from == to {
+		return ""
+	}
+	return to
+}
+
+// RecordAlias adds alias to the aliases of the config.json entry for
+// hostname. Only that entry is rewritten, the rest of the file keeps its
+// layout.
+func (c *websiteConfig) RecordAlias(hostname, alias string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data, err := os.ReadFile(c.configPath)
+	if err != nil {
+		return fmt.Errorf("failed to read configuration file: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+		return errors.New("configuration file is not a list of sites")
+	}
+	for decoder.More() {
+		var entry json.RawMessage
+		if err := decoder.Decode(&entry); err != nil {
+			return fmt.Errorf("failed to parse JSON configuration: %w", err)
+		}
+		end := int(decoder.InputOffset())
+		start := end - len(entry)
+
+		updated, ok, err := addAlias(entry, hostname, alias)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if updated == nil {
+			return nil
+		}
+
+		var out bytes.Buffer
+		out.Write(data[:start])
+		out.Write(updated)
+		out.Write(data[end:])
+		if err := os.WriteFile(c.configPath, out.Bytes(), 0o644); err != nil {
+			return fmt.Errorf("failed to write configuration file: %w", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("no entry for %s in configuration file", hostname)
+}
+
+// entryField is one field of a config.json entry as written: the whitespace
+// before its key, what separates the key from the value, and where the value
+// sits in the entry.
+type entryField struct {
+	space      string
+	colon      string
+	start, end int
+}
+
+// addAlias returns entry with alias appended to its aliases when it is the
+// entry for hostname. Only the aliases value changes, or is added after the
+// hostname in the spacing of the hostname field, so the rest of the entry
+// keeps its layout byte for byte. ok is false for other entries and the
+// returned entry is nil when the alias is already listed.
+func addAlias(entry json.RawMessage, hostname, alias string) (updated []byte, ok bool, err error) {
+	decoder := json.NewDecoder(bytes.NewReader(entry))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil, false, nil
+	}
+
+	fields := make(map[string]entryField)
+	// More skips whitespace, so the space before a key is taken from where
+	// the previous value ended.
+	before := int(decoder.InputOffset())
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to parse JSON configuration: %w", err)
+		}
+		keyEnd := int(decoder.InputOffset())
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, false, fmt.Errorf("failed to parse JSON configuration: %w", err)
+		}
+		end := int(decoder.InputOffset())
+		start := end - len(value)
+
+		lead := entry[before:keyEnd]
+		lead = lead[:bytes.IndexByte(lead, '"')]
+		lead = lead[bytes.LastIndexByte(lead, ',')+1:]
+		key, _ := token.(string)
+		fields[key] = entryField{space: string(lead), colon: string(entry[keyEnd:start]), start: start, end: end}
+		before = end
+	}
+
+	host, found := fields["hostname"]
+	var entryHost string
+	if !found || json.Unmarshal(entry[host.start:host.end], &entryHost) != nil || !strings.EqualFold(entryHost, hostname) {
+		return nil, false, nil
+	}
+
+	var out bytes.Buffer
+	if field, found := fields["aliases"]; found {
+		raw := entry[field.start:field.end]
+		var aliases []string
+		if err := json.Unmarshal(raw, &aliases); err != nil {
+			return nil, false, fmt.Errorf("invalid aliases for %s: %w", hostname, err)
+		}
+		if slices.Contains(aliases, alias) {
+			return nil, true, nil
+		}
+		out.Write(entry[:field.start])
+		if err := writeAliases(&out, append(aliases, alias), raw, field.space); err != nil {
+			return nil, false, err
+		}
+		out.Write(entry[field.end:])
+		return out.Bytes(), true, nil
+	}
+
+	out.Write(entry[:host.end])
+	out.WriteString("," + host.space + `"aliases"` + host.colon)
+	if err := writeAliases(&out, []string{alias}, nil, host.space); err != nil {
+		return nil, false, err
+	}
+	out.Write(entry[host.end:])
+	return out.Bytes(), true, nil
+}
+
+// writeAliases writes a JSON list of aliases in the style of was, the list it
+// replaces: on one line unless was spread its items over several, in which
+// case they are indented like its first item. space is the whitespace before
+// the aliases key.
+func writeAliases(out *bytes.Buffer, aliases []string, was []byte, space string) error {
+	list, err := json.Marshal(aliases)
+	if err != nil {
+		return err
+	}
+	newline := bytes.IndexByte(was, '\n')
+	if newline < 0 {
+		out.Write(bytes.ReplaceAll(list, []byte(`","`), []byte(`", "`)))
+		return nil
+	}
+	prefix := space[strings.LastIndexByte(space, '\n')+1:]
+	item := was[newline+1:]
+	item = item[:len(item)-len(bytes.TrimLeft(item, " \t"))]
+	indent, _ := strings.CutPrefix(string(item), prefix)
+	return json.Indent(out, list, prefix, indent)
+}
@@ -0,0 +1,218 @@
+package clients
+
+import (
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeHost(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"Example.COM", "example.com"},
+		{"www.example.com", "example.com"},
+		{"example.com.", "example.com"},
+		{" www.Example.com. ", "example.com"},
+		{"komik-ü.com", "xn--komik--8ya.com"},
+		{"xn--komik--8ya.com", "xn--komik--8ya.com"},
+		{"komikcast*.com", "komikcast*.com"},
+		{"wwwexample.com", "wwwexample.com"},
+	}
+	for _, tt := range tests {
+		if got := normalizeHost(tt.host); got != tt.want {
+			t.Errorf("normalizeHost(%q) = %q, want %q", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestMatchHost(t *testing.T) {
+	tests := []struct {
+		pattern string
+		host    string
+		want    int
+	}{
+		{"example.com", "example.com", hostExactMatch},
+		{"www.Example.com", "example.com", hostExactMatch},
+		{"example.com", "example.org", hostNoMatch},
+		{"example.com", "sub.example.com", hostNoMatch},
+		// Globs.
+		{"komikcast*.com", "komikcast03.com", hostPatternMatch},
+		{"komikcast*.com", "komikcast.com", hostPatternMatch},
+		{"komikcast*.com", "komikcast.net", hostNoMatch},
+		{"*.example.com", "cdn.example.com", hostPatternMatch},
+		// A star spans dots too.
+		{"*.example.com", "a.b.example.com", hostPatternMatch},
+		{"mirror?.com", "mirror1.com", hostPatternMatch},
+		{"mirror[0-9].com", "mirrorx.com", hostNoMatch},
+		// Regular expressions match the whole host.
+		{`re:komik(cast)?\d*\.(com|net)`, "komikcast12.net", hostPatternMatch},
+		{`re:komik(cast)?\d*\.(com|net)`, "komik.com", hostPatternMatch},
+		{`re:komik\.com`, "old.komik.com", hostNoMatch},
+		{`re:komik(`, "komik", hostNoMatch},
+		// IDN hosts match in punycode or Unicode.
+		{"komik-ü.com", "xn--komik--8ya.com", hostExactMatch},
+		{"xn--komik--8ya.com", normalizeHost("komik-ü.com"), hostExactMatch},
+		{`re:komik-ü\.com`, "xn--komik--8ya.com", hostPatternMatch},
+		{"*-ü.com", "xn--komik--8ya.com", hostNoMatch},
+	}
+	for _, tt := range tests {
+		if got := matchHost(tt.pattern, tt.host); got != tt.want {
+			t.Errorf("matchHost(%q, %q) = %d, want %d", tt.pattern, tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestScraperConfigMatchHost(t *testing.T) {
+	config := ScraperConfig{Hostname: "komikcast.com", Aliases: []string{"komikcast*.com", "re:kc\\d+\\.net"}}
+	tests := []struct {
+		host string
+		want int
+	}{
+		{"komikcast.com", hostExactMatch},
+		{"komikcast02.com", hostPatternMatch},
+		{"kc7.net", hostPatternMatch},
+		{"other.com", hostNoMatch},
+	}
+	for _, tt := range tests {
+		if got := config.matchHost(tt.host); got != tt.want {
+			t.Errorf("matchHost(%q) = %d, want %d", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestMovedHost(t *testing.T) {
+	tests := []struct {
+		requested string
+		final     string
+		want      string
+	}{
+		{"https://komikcast.com/series/x/", "https://komikcast03.com/series/x/", "komikcast03.com"},
+		{"https://komikcast.com/series/x/", "https://www.komikcast.com/series/x/", ""},
+		{"https://komikcast.com/series/x/", "https://komikcast.com/other/", ""},
+	}
+	for _, tt := range tests {
+		final, err := url.Parse(tt.final)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := movedHost(tt.requested, final); got != tt.want {
+			t.Errorf("movedHost(%q, %q) = %q, want %q", tt.requested, tt.final, got, tt.want)
+		}
+	}
+	if got := movedHost("https://komikcast.com/", nil); got != "" {
+		t.Errorf("movedHost without final URL = %q, want none", got)
+	}
+}
+
+func TestRecordAlias(t *testing.T) {
+	const config = `[
+  {
+    "hostname": "a.com",
+    "list_chapter_url": "ul a",
+    "series": { "title": "h1" }
+  },
+  { "hostname": "b.com", "extends": "madara" },
+  {
+    "hostname":"c.com",
+    "aliases": ["c1.com"],
+    "order": "reverse"
+  },
+	{
+		"hostname": "d.com",
+		"aliases": [
+			"d1.com"
+		]
+	}
+]
+`
+	tests := []struct {
+		name     string
+		hostname string
+		alias    string
+		// want is the entry after the change, the rest of the file must
+		// stay as it was.
+		was, want string
+		wantErr   string
+	}{
+		{
+			name: "multi-line entry without aliases", hostname: "a.com", alias: "a2.com",
+			was: `"hostname": "a.com",
+    "list_chapter_url"`,
+			want: `"hostname": "a.com",
+    "aliases": ["a2.com"],
+    "list_chapter_url"`,
+		},
+		{
+			name: "one-line entry", hostname: "B.com", alias: "b2.com",
+			was:  `{ "hostname": "b.com", "extends": "madara" }`,
+			want: `{ "hostname": "b.com", "aliases": ["b2.com"], "extends": "madara" }`,
+		},
+		{
+			name: "one-line aliases", hostname: "c.com", alias: "c2.com",
+			was:  `"aliases": ["c1.com"],`,
+			want: `"aliases": ["c1.com", "c2.com"],`,
+		},
+		{
+			name: "multi-line aliases with tabs", hostname: "d.com", alias: "d2.com",
+			was: `"aliases": [
+			"d1.com"
+		]`,
+			want: `"aliases": [
+			"d1.com",
+			"d2.com"
+		]`,
+		},
+		{name: "alias already listed", hostname: "c.com", alias: "c1.com"},
+		{name: "unknown site", hostname: "e.com", alias: "e2.com", wantErr: "no entry for e.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTestConfig(t, config)
+			w := &websiteConfig{configPath: path}
+			err := w.RecordAlias(tt.hostname, tt.alias)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := config
+			if tt.was != "" {
+				if !strings.Contains(config, tt.was) {
+					t.Fatalf("test config lacks %q", tt.was)
+				}
+				want = strings.Replace(config, tt.was, tt.want, 1)
+			}
+			if string(data) != want {
+				t.Errorf("config.json after RecordAlias:\n%s\nwant:\n%s", data, want)
+			}
+
+			// The rewritten file must still load, with the alias in place.
+			configs, err := loadSiteConfigs(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(configs) != 4 {
+				t.Fatalf("loaded %d sites, want 4", len(configs))
+			}
+			if tt.wantErr != "" {
+				return
+			}
+			for _, site := range configs {
+				if strings.EqualFold(site.Hostname, tt.hostname) && !slices.Contains(site.Aliases, tt.alias) {
+					t.Errorf("aliases of %s = %v, want %s among them", site.Hostname, site.Aliases, tt.alias)
+				}
+			}
+		})
+	}
+}
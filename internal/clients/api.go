@@ -0,0 +1,268 @@
+package clients
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pwnholic/comdown/internal"
+)
+
+// ModeAPI makes a site read its chapter and image lists from JSON endpoints
+// instead of HTML pages.
+const ModeAPI = "api"
+
+var placeholderPattern = regexp.MustCompile(`\{([^{}]+)\}`)
+
+// APIConfig holds the endpoints of a site in api mode.
+type APIConfig struct {
+	Chapters APIList `json:"chapters"`
+	Images   APIList `json:"images"`
+}
+
+// APIList describes one JSON endpoint and how to turn it into links.
+//
+// URL is a template for the request. Params are regular expressions run over
+// the page URL (the series URL for chapters, the chapter URL for images) whose
+// first group becomes a placeholder of the same name. Items is the path of
+// the array to read, such as "data" or "chapter.data", and Link and Text are
+// templates filled in for every item of it.
+//
+// Templates know {url}, {origin}, every param, {item} for the current item
+// and {item.path} for fields below it, and any other {path} is looked up from
+// the root of the response, so image links can be built like
+// "{baseUrl}/data/{chapter.hash}/{item}".
+type APIList struct {
+	URL    string            `json:"url"`
+	Params map[string]string `json:"params"`
+	Items  string            `json:"items"`
+	Link   string            `json:"link"`
+	Text   string            `json:"text"`
+}
+
+// collectAPILinks reads a chapter list from the chapters endpoint, in the
+// order the API returns it.
+func (c *clientRequest) collectAPILinks(metadata *ComicMetadata) ([]Chapter, error) {
+	items, err := c.fetchAPIList(metadata, metadata.API.Chapters)
+	if err != nil {
+		return nil, err
+	}
+
+	links := make([]Chapter, 0, len(items))
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		link, err := completeURL(item.link, metadata.URL)
+		if err != nil || seen[link] {
+			continue
+		}
+		seen[link] = true
+		links = append(links, Chapter{URL: link, Text: item.text})
+	}
+	internal.InfoLog("Found %d chapters from API %s\n", len(links), metadata.URL)
+	return links, nil
+}
+
+// collectAPIImages reads the image list of a chapter from the images endpoint.
+func (c *clientRequest) collectAPIImages(metadata *ComicMetadata) ([]string, error) {
+	items, err := c.fetchAPIList(metadata, metadata.API.Images)
+	if err != nil {
+		return nil, err
+	}
+
+	links := make([]string, 0, len(items))
+	for _, item := range items {
+		if isURLValid(item.link) {
+			links = append(links, item.link)
+		}
+	}
+	internal.InfoLog("Found %d images from API for %s\n", len(links), metadata.URL)
+	return links, nil
+}
+
+type apiItem struct {
+	link string
+	text string
+}
+
+func (c *clientRequest) fetchAPIList(metadata *ComicMetadata, list APIList) ([]apiItem, error) {
+	pageURL := metadata.URL
+	if list.URL == "" || list.Link == "" {
+		return nil, errors.New("api list needs a url and a link template")
+	}
+
+	values, err := apiValues(list.Params, pageURL)
+	if err != nil {
+		return nil, err
+	}
+
+	requestURL, err := fillJSONTemplate(list.URL, func(name string) (string, bool) {
+		value, ok := values[name]
+		return value, ok
+	})
+	if err != nil {
+		return nil, fmt.Errorf("api url %s: %w", list.URL, err)
+	}
+	root, err := c.fetchJSON(metadata, requestURL)
+	if err != nil {
+		return nil, err
+	}
+
+	found, ok := lookupPath(root, list.Items)
+	if !ok {
+		return nil, fmt.Errorf("no %q in response from %s", list.Items, requestURL)
+	}
+	array, ok := found.([]any)
+	if !ok {
+		return nil, fmt.Errorf("%q in response from %s is not a list", list.Items, requestURL)
+	}
+
+	items := make([]apiItem, 0, len(array))
+	for i, element := range array {
+		resolve := func(name string) (string, bool) {
+			if value, ok := values[name]; ok {
+				return value, true
+			}
+			if path, ok := strings.CutPrefix(name, "item"); ok && (path == "" || path[0] == '.' || path[0] == '[') {
+				return jsonValue(element, strings.TrimPrefix(path, "."))
+			}
+			return jsonValue(root, name)
+		}
+		link, err := fillJSONTemplate(list.Link, resolve)
+		if err != nil {
+			return nil, fmt.Errorf("link of %s[%d] from %s: %w", list.Items, i, requestURL, err)
+		}
+		text, err := fillJSONTemplate(list.Text, resolve)
+		if err != nil {
+			return nil, fmt.Errorf("text of %s[%d] from %s: %w", list.Items, i, requestURL, err)
+		}
+		items = append(items, apiItem{link: link, text: text})
+	}
+	return items, nil
+}
+
+func (c *clientRequest) fetchJSON(metadata *ComicMetadata, requestURL string) (any, error) {
+	response, err := c.pageRequest(metadata.ScraperConfig).SetHeader("Accept", "application/json").Get(requestURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch URL: %w", err)
+	}
+	defer response.Body.Close()
+
+	checkBlockStatus(response)
+
+	if response.StatusCode() != http.StatusOK {
+		return nil, fmt.Errorf("API %s returned status %d", requestURL, response.StatusCode())
+	}
+
+	decoder := json.NewDecoder(response.Body)
+	decoder.UseNumber()
+	var root any
+	if err := decoder.Decode(&root); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON from %s: %w", requestURL, err)
+	}
+	return root, nil
+}
+
+// apiValues collects the fixed placeholders for pageURL.
+func apiValues(params map[string]string, pageURL string) (map[string]string, error) {
+	values := map[string]string{"url": strings.TrimSuffix(pageURL, "/")}
+	if parsed, err := url.Parse(pageURL); err == nil {
+		values["origin"] = parsed.Scheme + "://" + parsed.Host
+	}
+
+	for name, pattern := range params {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern for %s: %w", name, err)
+		}
+		match := re.FindStringSubmatch(pageURL)
+		if len(match) < 2 {
+			return nil, fmt.Errorf("could not read %s from %s", name, pageURL)
+		}
+		values[name] = match[1]
+	}
+	return values, nil
+}
+
+// fillJSONTemplate fills every placeholder of template through resolve. A
+// placeholder without a value is an error, so a path that does not match the
+// response never turns into a broken link.
+func fillJSONTemplate(template string, resolve func(name string) (string, bool)) (string, error) {
+	var missing string
+	filled := placeholderPattern.ReplaceAllStringFunc(template, func(placeholder string) string {
+		name := placeholder[1 : len(placeholder)-1]
+		value, ok := resolve(name)
+		if !ok && missing == "" {
+			missing = name
+		}
+		return value
+	})
+	if missing != "" {
+		return "", fmt.Errorf("no value for {%s}", missing)
+	}
+	return filled, nil
+}
+
+// jsonValue looks up path below value and formats what it finds as text. A
+// null counts as an empty value, like the chapter number of a oneshot, a
+// missing key or an object does not count at all.
+func jsonValue(value any, path string) (string, bool) {
+	found, ok := lookupPath(value, path)
+	if !ok {
+		return "", false
+	}
+	switch v := found.(type) {
+	case nil:
+		return "", true
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		return strconv.FormatBool(v), true
+	default:
+		return "", false
+	}
+}
+
+// lookupPath follows a JSONPath-like expression such as "$.data[0].id" or
+// "chapter.data" into a decoded JSON value. An empty path is the value
+// itself.
+func lookupPath(value any, path string) (any, bool) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	for path != "" {
+		var key string
+		if strings.HasPrefix(path, "[") {
+			end := strings.Index(path, "]")
+			if end < 0 {
+				return nil, false
+			}
+			key, path = path[1:end], path[end+1:]
+			index, err := strconv.Atoi(key)
+			array, ok := value.([]any)
+			if err != nil || !ok || index < 0 || index >= len(array) {
+				return nil, false
+			}
+			value = array[index]
+		} else {
+			end := strings.IndexAny(path, ".[")
+			if end < 0 {
+				end = len(path)
+			}
+			key, path = path[:end], path[end:]
+			object, ok := value.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			if value, ok = object[key]; !ok {
+				return nil, false
+			}
+		}
+		path = strings.TrimPrefix(path, ".")
+	}
+	return value, true
+}
@@ -0,0 +1,202 @@
+package clients
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// MangaDex-shaped responses: the chapter feed of a manga and the at-home
+// server of a chapter.
+const (
+	testFeedJSON = `{"result": "ok", "data": [
+  {"id": "c1", "attributes": {"chapter": "1", "title": "Start", "pages": 20, "official": false}},
+  {"id": "c2", "attributes": {"chapter": "1.5", "title": null}},
+  {"id": "c3", "attributes": {"chapter": null, "title": "Oneshot"}}
+]}`
+	testAtHomeJSON = `{"result": "ok", "baseUrl": "https://uploads.example.org",
+  "chapter": {"hash": "abc123", "data": ["1.png", "2.png"], "dataSaver": ["1.jpg"]}}`
+)
+
+func decodeTestJSON(t *testing.T, data string) any {
+	t.Helper()
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
+
+func TestLookupPath(t *testing.T) {
+	feed := decodeTestJSON(t, testFeedJSON)
+	atHome := decodeTestJSON(t, testAtHomeJSON)
+	tests := []struct {
+		root any
+		path string
+		want string
+		ok   bool
+	}{
+		{atHome, "baseUrl", "https://uploads.example.org", true},
+		{atHome, "$.baseUrl", "https://uploads.example.org", true},
+		{atHome, "chapter.hash", "abc123", true},
+		{atHome, "chapter.data[1]", "2.png", true},
+		{atHome, "$.chapter.dataSaver[0]", "1.jpg", true},
+		{feed, "data[0].id", "c1", true},
+		{feed, "data[0].attributes.pages", "20", true},
+		{feed, "data[0].attributes.official", "false", true},
+		{feed, "data[1].attributes.title", "", true},
+		{feed, "result", "ok", true},
+		// Missing keys, bad indexes and values that are not text.
+		{atHome, "chapter.missing", "", false},
+		{atHome, "chapter.data[2]", "", false},
+		{atHome, "chapter.data[-1]", "", false},
+		{atHome, "chapter.data[x]", "", false},
+		{atHome, "chapter.data[0", "", false},
+		{atHome, "baseUrl.host", "", false},
+		{atHome, "chapter", "", false},
+		{feed, "data.id", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := jsonValue(tt.root, tt.path)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("jsonValue(%q) = %q, %v, want %q, %v", tt.path, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	if found, ok := lookupPath(atHome, ""); !ok || found == nil {
+		t.Error("empty path should be the value itself")
+	}
+	if found, ok := lookupPath(atHome, "chapter.data"); !ok || len(found.([]any)) != 2 {
+		t.Errorf("lookupPath(chapter.data) = %v, %v, want the list", found, ok)
+	}
+}
+
+func TestFillJSONTemplate(t *testing.T) {
+	values := map[string]string{"url": "https://example.com/x", "id": "42"}
+	resolve := func(name string) (string, bool) {
+		value, ok := values[name]
+		return value, ok
+	}
+	tests := []struct {
+		template string
+		want     string
+		wantErr  string
+	}{
+		{template: "", want: ""},
+		{template: "plain", want: "plain"},
+		{template: "{url}/api/{id}?q={id}", want: "https://example.com/x/api/42?q=42"},
+		{template: "{url}/{missing}/{other}", wantErr: "no value for {missing}"},
+	}
+	for _, tt := range tests {
+		got, err := fillJSONTemplate(tt.template, resolve)
+		if tt.wantErr != "" {
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("fillJSONTemplate(%q) error = %v, want %q", tt.template, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("fillJSONTemplate(%q) = %q, %v, want %q", tt.template, got, err, tt.want)
+		}
+	}
+}
+
+func TestFetchAPIList(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/manga/m1/feed":
+			fmt.Fprint(w, testFeedJSON)
+		case "/at-home/server/c1":
+			fmt.Fprint(w, testAtHomeJSON)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	chapters := APIList{
+		URL:    server.URL + "/manga/{id}/feed",
+		Params: map[string]string{"id": `title/(\w+)`},
+		Items:  "data",
+		Link:   "{origin}/chapter/{item.id}",
+		Text:   "{item.attributes.chapter}",
+	}
+	images := APIList{
+		URL:    server.URL + "/at-home/server/{id}",
+		Params: map[string]string{"id": `chapter/(\w+)`},
+		Items:  "chapter.data",
+		Link:   "{baseUrl}/data/{chapter.hash}/{item}",
+	}
+	tests := []struct {
+		name    string
+		pageURL string
+		list    APIList
+		want    []apiItem
+		wantErr string
+	}{
+		{
+			name: "chapters", pageURL: "https://mangadex.example/title/m1", list: chapters,
+			want: []apiItem{
+				{link: "https://mangadex.example/chapter/c1", text: "1"},
+				{link: "https://mangadex.example/chapter/c2", text: "1.5"},
+				{link: "https://mangadex.example/chapter/c3", text: ""},
+			},
+		},
+		{
+			name: "images from item and root paths", pageURL: "https://mangadex.example/chapter/c1", list: images,
+			want: []apiItem{
+				{link: "https://uploads.example.org/data/abc123/1.png"},
+				{link: "https://uploads.example.org/data/abc123/2.png"},
+			},
+		},
+		{
+			name: "missing item field", pageURL: "https://mangadex.example/title/m1",
+			list:    APIList{URL: chapters.URL, Params: chapters.Params, Items: "data", Link: "{origin}/chapter/{item.slug}"},
+			wantErr: "link of data[0]",
+		},
+		{
+			name: "missing root field", pageURL: "https://mangadex.example/chapter/c1",
+			list:    APIList{URL: images.URL, Params: images.Params, Items: "chapter.data", Link: "{baseUrl}/{chapter.key}/{item}"},
+			wantErr: "no value for {chapter.key}",
+		},
+		{
+			name: "missing text field", pageURL: "https://mangadex.example/title/m1",
+			list:    APIList{URL: chapters.URL, Params: chapters.Params, Items: "data", Link: chapters.Link, Text: "{item.attributes.volume}"},
+			wantErr: "text of data[0]",
+		},
+		{
+			name: "unknown placeholder in the url", pageURL: "https://mangadex.example/title/m1",
+			list:    APIList{URL: server.URL + "/manga/{manga}/feed", Items: "data", Link: chapters.Link},
+			wantErr: "no value for {manga}",
+		},
+		{
+			name: "items that are not a list", pageURL: "https://mangadex.example/chapter/c1",
+			list:    APIList{URL: images.URL, Params: images.Params, Items: "chapter.hash", Link: "{item}"},
+			wantErr: "is not a list",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metadata := &ComicMetadata{URL: tt.pageURL}
+			items, err := NewClientRequest(nil).fetchAPIList(metadata, tt.list)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(items, tt.want) {
+				t.Errorf("fetchAPIList = %v, want %v", items, tt.want)
+			}
+		})
+	}
+}
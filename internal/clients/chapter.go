@@ -0,0 +1,120 @@
+package clients
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// ChapterInfo reads the title, release date and scanlation group of every
+// chapter from the listing. The selectors are relative to the chapter row,
+// the closest ancestor of the chapter link matching Row, or to the link
+// itself when Row is empty.
+//
+// Dates are parsed with DateLayouts, Go time layouts tried in order, then
+// with common English layouts. Indonesian month names are understood, and so
+// are relative dates such as "2 hari lalu" or "3 days ago".
+type ChapterInfo struct {
+	Row         string        `json:"row"`
+	Title       ValueSelector `json:"title"`
+	Date        ValueSelector `json:"date"`
+	DateLayouts []string      `json:"date_layouts"`
+	Scanlator   ValueSelector `json:"scanlator"`
+}
+
+func (i ChapterInfo) enabled() bool {
+	return i.Title.Selector != "" || i.Date.Selector != "" || i.Scanlator.Selector != ""
+}
+
+var defaultDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"January 2, 2006",
+	"Jan 2, 2006",
+	"January 2 2006",
+	"Jan 2 2006",
+	"2 January 2006",
+	"2 Jan 2006",
+	"02/01/2006",
+}
+
+// indonesianMonths maps Indonesian month names and abbreviations onto the
+// English ones time layouts know.
+var indonesianMonths = strings.NewReplacer(
+	"Januari", "January", "Februari", "February", "Maret", "March",
+	"Mei", "May", "Juni", "June", "Juli", "July", "Agustus", "August",
+	"Agu", "Aug", "Oktober", "October", "Okt", "Oct", "Desember", "December", "Des", "Dec",
+)
+
+var relativeDatePattern = regexp.MustCompile(`(?i)\b(\d+|an?|one|se)\s*(detik|second|menit|minute|min|jam|hour|hari|day|minggu|week|bulan|month|tahun|year)s?\b`)
+
+// relativeUnits is the length of every unit relative dates count in. Months
+// and years are handled apart so they land on calendar dates.
+var relativeUnits = map[string]time.Duration{
+	"detik": time.Second, "second": time.Second,
+	"menit": time.Minute, "minute": time.Minute, "min": time.Minute,
+	"jam": time.Hour, "hour": time.Hour,
+	"hari": 24 * time.Hour, "day": 24 * time.Hour,
+	"minggu": 7 * 24 * time.Hour, "week": 7 * 24 * time.Hour,
+}
+
+// readChapterInfo fills in the chapter details read from the row of link.
+func readChapterInfo(chapter *Chapter, link *goquery.Selection, info ChapterInfo) {
+	row := link
+	if info.Row != "" {
+		if closest := link.Closest(info.Row); closest.Length() > 0 {
+			row = closest
+		}
+	}
+
+	chapter.Title = firstValue(info.Title.values(row))
+	chapter.Scanlator = firstValue(info.Scanlator.values(row))
+	if date := firstValue(info.Date.values(row)); date != "" {
+		chapter.Date, _ = parseChapterDate(date, info.DateLayouts, time.Now())
+	}
+}
+
+// parseChapterDate reads a release date as listings show it, relative to
+// now for dates like "kemarin" or "5 jam yang lalu".
+func parseChapterDate(value string, layouts []string, now time.Time) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	lower := strings.ToLower(value)
+	switch {
+	case lower == "":
+		return time.Time{}, false
+	case strings.Contains(lower, "baru saja") || strings.Contains(lower, "just now") ||
+		strings.Contains(lower, "hari ini") || strings.Contains(lower, "today"):
+		return now, true
+	case strings.Contains(lower, "kemarin") || strings.Contains(lower, "yesterday"):
+		return now.AddDate(0, 0, -1), true
+	}
+
+	if match := relativeDatePattern.FindStringSubmatch(lower); match != nil {
+		count, err := strconv.Atoi(match[1])
+		if err != nil {
+			count = 1
+		}
+		switch unit := match[2]; unit {
+		case "bulan", "month":
+			return now.AddDate(0, -count, 0), true
+		case "tahun", "year":
+			return now.AddDate(-count, 0, 0), true
+		default:
+			return now.Add(-time.Duration(count) * relativeUnits[unit]), true
+		}
+	}
+
+	value = indonesianMonths.Replace(value)
+	for _, layouts := range [][]string{layouts, defaultDateLayouts} {
+		for _, layout := range layouts {
+			if date, err := time.ParseInLocation(layout, value, now.Location()); err == nil {
+				return date, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
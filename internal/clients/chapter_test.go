@@ -0,0 +1,102 @@
+package clients
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestParseChapterDate(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		value   string
+		layouts []string
+		want    time.Time
+		ok      bool
+	}{
+		// Relative dates, Indonesian and English.
+		{value: "baru saja", want: now, ok: true},
+		{value: "Just now", want: now, ok: true},
+		{value: "Hari ini", want: now, ok: true},
+		{value: "kemarin", want: now.AddDate(0, 0, -1), ok: true},
+		{value: "Yesterday", want: now.AddDate(0, 0, -1), ok: true},
+		{value: "5 jam yang lalu", want: now.Add(-5 * time.Hour), ok: true},
+		{value: "30 menit lalu", want: now.Add(-30 * time.Minute), ok: true},
+		{value: "2 hari lalu", want: day(2026, 10, 14).Add(12 * time.Hour), ok: true},
+		{value: "2 minggu lalu", want: day(2026, 10, 2).Add(12 * time.Hour), ok: true},
+		{value: "sebulan yang lalu", want: day(2026, 9, 16).Add(12 * time.Hour), ok: true},
+		{value: "setahun lalu", want: day(2025, 10, 16).Add(12 * time.Hour), ok: true},
+		{value: "3 days ago", want: day(2026, 10, 13).Add(12 * time.Hour), ok: true},
+		{value: "1 mins ago", want: now.Add(-time.Minute), ok: true},
+		{value: "an hour ago", want: now.Add(-time.Hour), ok: true},
+		{value: "2 months ago", want: day(2026, 8, 16).Add(12 * time.Hour), ok: true},
+
+		// Absolute dates with Indonesian month names.
+		{value: "Mei 5, 2023", want: day(2023, 5, 5), ok: true},
+		{value: "5 Agustus 2023", want: day(2023, 8, 5), ok: true},
+		{value: "Desember 1, 2022", want: day(2022, 12, 1), ok: true},
+		{value: "17 Okt 2024", want: day(2024, 10, 17), ok: true},
+
+		// Absolute dates in the default layouts.
+		{value: "2024-03-09", want: day(2024, 3, 9), ok: true},
+		{value: "March 9, 2024", want: day(2024, 3, 9), ok: true},
+		{value: "  Mar 9 2024  ", want: day(2024, 3, 9), ok: true},
+		{value: "09/03/2024", want: day(2024, 3, 9), ok: true},
+		{value: "2024-03-09T08:30:00Z", want: day(2024, 3, 9).Add(8*time.Hour + 30*time.Minute), ok: true},
+
+		// Configured layouts come before the defaults.
+		{value: "05.08.2023", layouts: []string{"02.01.2006"}, want: day(2023, 8, 5), ok: true},
+		{value: "03/09/2024", layouts: []string{"01/02/2006"}, want: day(2024, 3, 9), ok: true},
+
+		// Values that are not dates.
+		{value: ""},
+		{value: "garbage"},
+		{value: "05.08.2023"},
+	}
+	for _, tt := range tests {
+		got, ok := parseChapterDate(tt.value, tt.layouts, now)
+		if ok != tt.ok || !got.Equal(tt.want) {
+			t.Errorf("parseChapterDate(%q, %q) = %v, %v, want %v, %v", tt.value, tt.layouts, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestReadChapterInfo(t *testing.T) {
+	document, err := goquery.NewDocumentFromReader(strings.NewReader(`<ul>
+<li class="row"><a href="/c/1">Chapter 1</a><span class="name">The Start</span><span class="date">2024-03-09</span><i class="group">TeamX</i></li>
+<li class="row"><a href="/c/2">Chapter 2</a></li>
+</ul>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := ChapterInfo{
+		Row:       "li.row",
+		Title:     ValueSelector{Selector: "span.name"},
+		Date:      ValueSelector{Selector: "span.date"},
+		Scanlator: ValueSelector{Selector: "i.group"},
+	}
+
+	tests := []struct {
+		link      string
+		title     string
+		date      time.Time
+		scanlator string
+	}{
+		{link: "/c/1", title: "The Start", date: time.Date(2024, 3, 9, 0, 0, 0, 0, time.Local), scanlator: "TeamX"},
+		{link: "/c/2"},
+	}
+	for _, tt := range tests {
+		var chapter Chapter
+		readChapterInfo(&chapter, document.Find(`a[href="`+tt.link+`"]`), info)
+		if chapter.Title != tt.title || !chapter.Date.Equal(tt.date) || chapter.Scanlator != tt.scanlator {
+			t.Errorf("%s: got %q, %v, %q, want %q, %v, %q", tt.link,
+				chapter.Title, chapter.Date, chapter.Scanlator, tt.title, tt.date, tt.scanlator)
+		}
+	}
+}
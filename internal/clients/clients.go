@@ -1,20 +1,96 @@
 package clients
 
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/pwnholic/comdown/internal"
+	"resty.dev/v3"
+)
+
+// Request is the HTTP side of the config-driven scraper, plus the image
+// conversion every scraper shares.
+type Request interface {
+	CollectLinks(metadata *ComicMetadata) ([]Chapter, error)
+	CollectSeriesInfo(metadata *ComicMetadata) (SeriesInfo, error)
+	CollectImgTagsLink(metadata *ComicMetadata) ([]string, error)
+	CollectRawImage(metadata *ComicMetadata, imgLink string) ([]byte, error)
+	ConvertImage(imgBytes []byte, mode ImageMode, enhance bool) ([]byte, error)
+}
+
+// Website reads the site descriptions in config.json.
+type Website interface {
+	GetHTMLTagAttrFromURL(rawURL string) *ScraperConfig
+	GetChapterNumber(link Chapter, rule ChapterNumberRule) (ChapterNumber, error)
+	RecordAlias(hostname, alias string) error
+}
+
 type RequestBuilder struct {
-	Request interface {
-		CollectLinks(metadata *ComicMetadata) ([]string, error)
-		CollectImgTagsLink(metadata *ComicMetadata) ([]string, error)
-		CollectImage(imgLink string, enhance bool) ([]byte, error)
-	}
-	Website interface {
-		GetHTMLTagAttrFromURL(rawURL string) *ScraperConfig
-		GetChapterNumber(urlRaw string) (string, error)
-	}
+	Request Request
+	Website Website
+	// SaveAliases lets config-driven scrapers add the new domain of a site
+	// that redirects to one to its aliases in config.json.
+	SaveAliases bool
+	// Duplicates overrides the duplicate policy of every site when set.
+	Duplicates DuplicatePolicy
+	client     *resty.Client
 }
 
 func NewRequestBuilder(t *HTTPClientOptions) *RequestBuilder {
+	request := NewClientRequest(t)
 	return &RequestBuilder{
-		Request: NewClientRequest(t),
+		Request: request,
 		Website: NewWebsiteConfig(),
+		client:  request.Client,
+	}
+}
+
+// Scraper picks the scraper for rawURL: a Go scraper registered for its
+// hostname, or else the config-driven scraper for its config.json entry.
+func (b *RequestBuilder) Scraper(rawURL string) (Scraper, error) {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse URL: %w", err)
+	}
+
+	host := parsedURL.Hostname()
+	if factory, ok := lookupScraper(normalizeHost(host)); ok {
+		internal.InfoLog("Using built-in scraper for domain: %s\n", host)
+		return factory(b.client), nil
+	}
+
+	config := b.Website.GetHTMLTagAttrFromURL(rawURL)
+	if config == nil {
+		return nil, errors.New("HTML attribute not found or website unsupported")
+	}
+	return &configScraper{config: *config, request: b.Request, website: b.Website, saveAliases: b.SaveAliases}, nil
+}
+
+// ListChapters lists the chapters of a series with scraper and keeps the ones
+// selection picks, with duplicates resolved among them. Selecting first keeps
+// rules like most-pages from fetching chapters that are not downloaded. The
+// policy is b.Duplicates when set, else the scraper's own when it has one
+// (config.json entries do), else DefaultDuplicatePolicy.
+func (b *RequestBuilder) ListChapters(scraper Scraper, seriesURL string, selection ChapterSelection) ([]Chapter, error) {
+	chapters, err := scraper.Chapters(seriesURL)
+	if err != nil {
+		return nil, err
+	}
+	if chapters, err = selection.Select(chapters, scraper.ChapterNumber); err != nil {
+		return nil, err
+	}
+
+	policy := b.Duplicates
+	if policy == nil {
+		policy = DefaultDuplicatePolicy
+		if site, ok := scraper.(interface {
+			DuplicatePolicy() (DuplicatePolicy, error)
+		}); ok {
+			if policy, err = site.DuplicatePolicy(); err != nil {
+				return nil, err
+			}
+		}
 	}
+	return ResolveDuplicates(chapters, policy, scraper.ChapterNumber, scraper.Pages), nil
 }
@@ -0,0 +1,207 @@
+package clients
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pwnholic/comdown/internal"
+)
+
+// Duplicate preferences, see DuplicatePolicy.
+const (
+	DuplicateNewest    = "newest"
+	DuplicateMostPages = "most-pages"
+	DuplicateFirst     = "first"
+	DuplicateLast      = "last"
+	DuplicateKeepAll   = "keep-all"
+	// duplicateGroupPrefix starts a preference for a scanlation group, as in
+	// "group:TeamX".
+	duplicateGroupPrefix = "group:"
+)
+
+// DefaultDuplicatePolicy keeps the newest copy of a chapter.
+var DefaultDuplicatePolicy = DuplicatePolicy{DuplicateNewest}
+
+// DuplicatePolicy decides which copy of a chapter listed several times is
+// downloaded, for listings with several groups, re-uploads or mirrors. It is
+// a comma separated list of preferences tried in order until one copy is
+// left:
+//
+//	group:NAME  copies whose scanlator contains NAME
+//	newest      the latest release date
+//	most-pages  the most images, which fetches every copy's page list
+//	first       the copy listed first once the list is ordered
+//	last        the copy listed last, which also breaks any remaining tie
+//	keep-all    every copy, with the scanlator or v1, v2... as Variant
+type DuplicatePolicy []string
+
+// ParseDuplicatePolicy reads a policy such as "group:TeamX,newest". An empty
+// value gives the default policy.
+func ParseDuplicatePolicy(value string) (DuplicatePolicy, error) {
+	var policy DuplicatePolicy
+	for part := range strings.SplitSeq(value, ",") {
+		rule := strings.TrimSpace(part)
+		if rule == "" {
+			continue
+		}
+		if group, ok := strings.CutPrefix(strings.ToLower(rule), duplicateGroupPrefix); ok {
+			if strings.TrimSpace(group) == "" {
+				return nil, fmt.Errorf("duplicate rule %q needs a group name", rule)
+			}
+			policy = append(policy, duplicateGroupPrefix+strings.TrimSpace(rule[len(duplicateGroupPrefix):]))
+			continue
+		}
+		switch rule = strings.ToLower(rule); rule {
+		case DuplicateNewest, DuplicateMostPages, DuplicateFirst, DuplicateLast, DuplicateKeepAll:
+			policy = append(policy, rule)
+		default:
+			return nil, fmt.Errorf("unknown duplicate rule %q, use group:NAME, %s, %s, %s, %s or %s", rule,
+				DuplicateNewest, DuplicateMostPages, DuplicateFirst, DuplicateLast, DuplicateKeepAll)
+		}
+	}
+	if len(policy) == 0 {
+		return DefaultDuplicatePolicy, nil
+	}
+	return policy, nil
+}
+
+// ResolveDuplicates keeps one copy of every chapter number, chosen by
+// policy, or every copy with a Variant set when the policy keeps all. Copies
+// are chapters whose numbers compare equal, so 12.1 and 12.10 are different
+// chapters. The order of chapters is kept and chapters whose number cannot
+// be read are left alone. pages is only called for the most-pages rule.
+func ResolveDuplicates(chapters []Chapter, policy DuplicatePolicy, number func(Chapter) (ChapterNumber, error), pages func(chapterURL string) ([]string, error)) []Chapter {
+	// Numbers are keyed without their volume, which the sites show on
+	// some copies only.
+	copies := make(map[ChapterNumber][]int)
+	var numbers []ChapterNumber
+	for i, chapter := range chapters {
+		n, err := number(chapter)
+		if err != nil {
+			continue
+		}
+		key := ChapterNumber{Chapter: n.Chapter, Sub: n.Sub}
+		if _, ok := copies[key]; !ok {
+			numbers = append(numbers, key)
+		}
+		copies[key] = append(copies[key], i)
+	}
+
+	drop := make([]bool, len(chapters))
+	for _, key := range numbers {
+		indexes := copies[key]
+		if len(indexes) < 2 {
+			continue
+		}
+		label := key.label()
+
+		if slices.Contains(policy, DuplicateKeepAll) {
+			nameVariants(chapters, indexes)
+			internal.InfoLog("Chapter %s is listed %d times, keeping all of them\n", label, len(indexes))
+			continue
+		}
+
+		chosen := policy.choose(chapters, indexes, pages)
+		for _, i := range indexes {
+			drop[i] = i != chosen
+		}
+		internal.InfoLog("Chapter %s is listed %d times, chose %s\n", label, len(indexes), describeCopy(chapters[chosen]))
+	}
+
+	resolved := make([]Chapter, 0, len(chapters))
+	for i, chapter := range chapters {
+		if !drop[i] {
+			resolved = append(resolved, chapter)
+		}
+	}
+	return resolved
+}
+
+// choose narrows the copies down rule by rule and falls back to the last one.
+func (p DuplicatePolicy) choose(chapters []Chapter, indexes []int, pages func(string) ([]string, error)) int {
+	candidates := slices.Clone(indexes)
+	for _, rule := range p {
+		if len(candidates) == 1 {
+			break
+		}
+		switch {
+		case strings.HasPrefix(rule, duplicateGroupPrefix):
+			group := strings.ToLower(strings.TrimPrefix(rule, duplicateGroupPrefix))
+			candidates = keepBest(candidates, func(i int) int {
+				if strings.Contains(strings.ToLower(chapters[i].Scanlator), group) {
+					return 1
+				}
+				return 0
+			})
+		case rule == DuplicateNewest:
+			candidates = keepBest(candidates, func(i int) int {
+				if chapters[i].Date.IsZero() {
+					return 0
+				}
+				return int(chapters[i].Date.Unix())
+			})
+		case rule == DuplicateMostPages && pages != nil:
+			candidates = keepBest(candidates, func(i int) int {
+				images, err := pages(chapters[i].URL)
+				if err != nil {
+					internal.WarningLog("Could not count pages of %s: %v\n", chapters[i].URL, err)
+					return 0
+				}
+				return len(images)
+			})
+		case rule == DuplicateFirst:
+			candidates = candidates[:1]
+		case rule == DuplicateLast:
+			candidates = candidates[len(candidates)-1:]
+		}
+	}
+	return candidates[len(candidates)-1]
+}
+
+// keepBest keeps the candidates with the highest score.
+func keepBest(candidates []int, score func(int) int) []int {
+	scores := make([]int, len(candidates))
+	for i, candidate := range candidates {
+		scores[i] = score(candidate)
+	}
+	best := slices.Max(scores)
+	var kept []int
+	for i, candidate := range candidates {
+		if scores[i] == best {
+			kept = append(kept, candidate)
+		}
+	}
+	return kept
+}
+
+// nameVariants tells kept copies apart by their scanlator, or by their
+// position when the scanlators are missing or shared.
+func nameVariants(chapters []Chapter, indexes []int) {
+	seen := make(map[string]int, len(indexes))
+	for _, i := range indexes {
+		seen[strings.ToLower(chapters[i].Scanlator)]++
+	}
+	for n, i := range indexes {
+		if group := chapters[i].Scanlator; group != "" && seen[strings.ToLower(group)] == 1 {
+			chapters[i].Variant = group
+		} else {
+			chapters[i].Variant = "v" + strconv.Itoa(n+1)
+		}
+	}
+}
+
+func describeCopy(chapter Chapter) string {
+	var details []string
+	if chapter.Scanlator != "" {
+		details = append(details, chapter.Scanlator)
+	}
+	if !chapter.Date.IsZero() {
+		details = append(details, chapter.Date.Format("2006-01-02"))
+	}
+	if len(details) == 0 {
+		return chapter.URL
+	}
+	return fmt.Sprintf("%s (%s)", chapter.URL, strings.Join(details, ", "))
+}
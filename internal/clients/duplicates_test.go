@@ -0,0 +1,109 @@
+package clients
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestResolveDuplicates(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC) }
+	// Chapter 2 is listed three times, by two groups and a re-upload.
+	copies := func() []Chapter {
+		chapters := numberedChapters("1", "2", "2", "2", "3")
+		chapters[1].Scanlator, chapters[1].Date = "TeamX", day(1)
+		chapters[2].Scanlator, chapters[2].Date = "TeamY", day(5)
+		chapters[3].Scanlator, chapters[3].Date = "TeamX", day(3)
+		for i := range chapters {
+			chapters[i].URL += "#" + string(rune('a'+i))
+		}
+		return chapters
+	}
+	pageCounts := map[string]int{}
+	for i, chapter := range copies() {
+		pageCounts[chapter.URL] = []int{10, 20, 18, 25, 10}[i]
+	}
+
+	tests := []struct {
+		name      string
+		policy    string
+		chapters  []Chapter
+		want      []int
+		variants  []string
+		pageCalls int
+	}{
+		{name: "newest", policy: "newest", chapters: copies(), want: []int{0, 2, 4}},
+		{name: "group then newest", policy: "group:teamx,newest", chapters: copies(), want: []int{0, 3, 4}},
+		{name: "first", policy: "first", chapters: copies(), want: []int{0, 1, 4}},
+		{name: "last", policy: "last", chapters: copies(), want: []int{0, 3, 4}},
+		{name: "most pages", policy: "most-pages", chapters: copies(), want: []int{0, 3, 4}, pageCalls: 3},
+		{
+			name: "keep all", policy: "keep-all", chapters: copies(), want: []int{0, 1, 2, 3, 4},
+			variants: []string{"", "v1", "TeamY", "v3", ""},
+		},
+		{name: "no duplicates", policy: "most-pages", chapters: numberedChapters("1", "2"), want: []int{0, 1}},
+		// 12.1 and 12.10 are different chapters.
+		{name: "subs are not rounded", policy: "newest", chapters: numberedChapters("12.1", "12.10", "12.2"), want: []int{0, 1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := ParseDuplicatePolicy(tt.policy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			calls := 0
+			pages := func(chapterURL string) ([]string, error) {
+				calls++
+				return make([]string, pageCounts[chapterURL]), nil
+			}
+
+			resolved := ResolveDuplicates(slices.Clone(tt.chapters), policy, testChapterNumber, pages)
+			var want []Chapter
+			for _, i := range tt.want {
+				want = append(want, tt.chapters[i])
+			}
+			if len(resolved) != len(want) {
+				t.Fatalf("kept %v, want %v", chapterURLs(resolved), chapterURLs(want))
+			}
+			for i := range want {
+				if resolved[i].URL != want[i].URL {
+					t.Fatalf("kept %v, want %v", chapterURLs(resolved), chapterURLs(want))
+				}
+				if tt.variants != nil && resolved[i].Variant != tt.variants[i] {
+					t.Errorf("variant of %s = %q, want %q", resolved[i].URL, resolved[i].Variant, tt.variants[i])
+				}
+			}
+			if calls != tt.pageCalls {
+				t.Errorf("counted pages %d times, want %d", calls, tt.pageCalls)
+			}
+		})
+	}
+}
+
+func TestParseDuplicatePolicy(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    DuplicatePolicy
+		wantErr bool
+	}{
+		{value: "", want: DefaultDuplicatePolicy},
+		{value: " Group:TeamX , NEWEST ", want: DuplicatePolicy{"group:TeamX", DuplicateNewest}},
+		{value: "most-pages,last", want: DuplicatePolicy{DuplicateMostPages, DuplicateLast}},
+		{value: "group:", wantErr: true},
+		{value: "biggest", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDuplicatePolicy(tt.value)
+		if (err != nil) != tt.wantErr || !slices.Equal(got, tt.want) {
+			t.Errorf("ParseDuplicatePolicy(%q) = %v, %v, want %v", tt.value, got, err, tt.want)
+		}
+	}
+}
+
+func chapterURLs(chapters []Chapter) []string {
+	urls := make([]string, len(chapters))
+	for i, chapter := range chapters {
+		urls[i] = chapter.URL
+	}
+	return urls
+}
@@ -0,0 +1,56 @@
+package clients
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"resty.dev/v3"
+)
+
+// Referer values with a meaning of their own. Any other referer in a site
+// entry is sent as it is.
+const (
+	// RefererChapter sends the URL of the chapter the image belongs to.
+	RefererChapter = "chapter"
+	// RefererOrigin sends the scheme and host of the chapter URL.
+	RefererOrigin = "origin"
+)
+
+// pageRequest starts a request for a page of the site, carrying the headers
+// and cookies its entry declares.
+func (c *clientRequest) pageRequest(site ScraperConfig) *resty.Request {
+	request := c.Client.R().SetHeaders(site.Headers)
+	for name, value := range site.Cookies {
+		request.SetCookie(&http.Cookie{Name: name, Value: value})
+	}
+	return request
+}
+
+// imageRequest starts a request for an image of the chapter at chapterURL.
+// Image CDNs that refuse hotlinking get the Referer the site entry asks for.
+func (c *clientRequest) imageRequest(site ScraperConfig, chapterURL string) *resty.Request {
+	request := c.pageRequest(site).SetHeaders(site.ImageHeaders)
+	if referer := site.referer(chapterURL); referer != "" {
+		request.SetHeader("Referer", referer)
+	}
+	return request
+}
+
+// referer resolves the entry's Referer setting for chapterURL.
+func (s ScraperConfig) referer(chapterURL string) string {
+	switch strings.ToLower(s.Referer) {
+	case "":
+		return ""
+	case RefererChapter:
+		return chapterURL
+	case RefererOrigin:
+		parsed, err := url.Parse(chapterURL)
+		if err != nil || parsed.Host == "" {
+			return ""
+		}
+		return parsed.Scheme + "://" + parsed.Host + "/"
+	default:
+		return s.Referer
+	}
+}
@@ -0,0 +1,57 @@
+package clients
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ImageOriginal keeps the downloaded bytes exactly as served.
+	ImageOriginal = "original"
+	// ImageLossless keeps JPEG and PNG as served and turns every other
+	// format into PNG.
+	ImageLossless = "lossless"
+	// ImageJPEG re-encodes everything to JPEG. JPEGs are kept as served at
+	// quality 100 unless they are enhanced.
+	ImageJPEG = "jpeg"
+
+	defaultJPEGQuality = 100
+)
+
+// DefaultImageMode is what document formats get unless the CLI asks for
+// something else.
+var DefaultImageMode = ImageMode{Kind: ImageJPEG, Quality: defaultJPEGQuality}
+
+// ImageMode decides how downloaded images are converted before export.
+// Quality is only used by ImageJPEG.
+type ImageMode struct {
+	Kind    string
+	Quality int
+}
+
+// ParseImageMode reads "original", "lossless", "jpeg" or "jpeg:<quality>".
+func ParseImageMode(value string) (ImageMode, error) {
+	kind, quality, hasQuality := strings.Cut(strings.ToLower(strings.TrimSpace(value)), ":")
+	switch {
+	case kind == ImageOriginal && !hasQuality, kind == ImageLossless && !hasQuality:
+		return ImageMode{Kind: kind}, nil
+	case kind == ImageJPEG && !hasQuality:
+		return DefaultImageMode, nil
+	case kind == ImageJPEG:
+		q, err := strconv.Atoi(quality)
+		if err != nil || q < 1 || q > 100 {
+			return ImageMode{}, fmt.Errorf("invalid JPEG quality %q, use a number from 1 to 100", quality)
+		}
+		return ImageMode{Kind: ImageJPEG, Quality: q}, nil
+	default:
+		return ImageMode{}, fmt.Errorf("unknown image mode %q, use original, lossless or jpeg:<quality>", value)
+	}
+}
+
+func (m ImageMode) String() string {
+	if m.Kind == ImageJPEG {
+		return fmt.Sprintf("%s:%d", m.Kind, m.Quality)
+	}
+	return m.Kind
+}
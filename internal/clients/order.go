@@ -0,0 +1,88 @@
+package clients
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Chapter order policies for ScraperConfig.Order.
+const (
+	// OrderByNumber sorts chapters by their parsed number. It is the default.
+	OrderByNumber = "by-number"
+	// OrderAsListed keeps the order of the listing, for sites listing oldest
+	// first.
+	OrderAsListed = "as-listed"
+	// OrderReverse reverses the listing, for sites listing newest first.
+	OrderReverse = "reverse"
+)
+
+// orderChapters puts the chapters of a listing oldest first as policy says.
+//
+// Sorting by number reads newest-first listings from the bottom and is
+// stable, so chapters sharing a number stay in the order they were
+// published. Chapters without a number, such as extras, specials and pinned
+// announcements, are kept right after the numbered chapter published before
+// them, or first when none was.
+func orderChapters(chapters []Chapter, policy string, number func(Chapter) (ChapterNumber, error)) ([]Chapter, error) {
+	switch strings.ToLower(policy) {
+	case OrderAsListed:
+		return chapters, nil
+	case OrderReverse:
+		slices.Reverse(chapters)
+		return chapters, nil
+	case "", OrderByNumber:
+	default:
+		return nil, fmt.Errorf("unknown chapter order %q, use %s, %s or %s", policy, OrderByNumber, OrderAsListed, OrderReverse)
+	}
+
+	type numbered struct {
+		chapter Chapter
+		number  ChapterNumber
+		// extras are the unnumbered chapters listed after this one.
+		extras []Chapter
+	}
+	numbers := make([]ChapterNumber, len(chapters))
+	valid := make([]bool, len(chapters))
+	first, last := -1, -1
+	for i, chapter := range chapters {
+		n, err := number(chapter)
+		if err != nil {
+			continue
+		}
+		numbers[i], valid[i] = n, true
+		if first < 0 {
+			first = i
+		}
+		last = i
+	}
+	if first >= 0 && numbers[first].Compare(numbers[last]) > 0 {
+		slices.Reverse(chapters)
+		slices.Reverse(numbers)
+		slices.Reverse(valid)
+	}
+
+	var leading []Chapter
+	entries := make([]numbered, 0, len(chapters))
+	for i, chapter := range chapters {
+		switch {
+		case valid[i]:
+			entries = append(entries, numbered{chapter: chapter, number: numbers[i]})
+		case len(entries) == 0:
+			leading = append(leading, chapter)
+		default:
+			entries[len(entries)-1].extras = append(entries[len(entries)-1].extras, chapter)
+		}
+	}
+	slices.SortStableFunc(entries, func(a, b numbered) int {
+		return a.number.Compare(b.number)
+	})
+
+	ordered := make([]Chapter, 0, len(chapters))
+	ordered = append(ordered, leading...)
+	for _, entry := range entries {
+		ordered = append(ordered, entry.chapter)
+		ordered = append(ordered, entry.extras...)
+	}
+	return ordered, nil
+}
@@ -0,0 +1,82 @@
+package clients
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestOrderChapters(t *testing.T) {
+	extra := Chapter{URL: "https://example.com/extra/"}
+	announcement := Chapter{URL: "https://example.com/announcement/"}
+	prologue := Chapter{URL: "https://example.com/prologue/"}
+
+	tests := []struct {
+		name     string
+		policy   string
+		chapters []Chapter
+		want     []string
+		wantErr  bool
+	}{
+		{name: "oldest first", chapters: numberedChapters("1", "2", "3"), want: []string{"1", "2", "3"}},
+		{name: "newest first", chapters: numberedChapters("3", "2", "1"), want: []string{"1", "2", "3"}},
+		{name: "shuffled", policy: OrderByNumber, chapters: numberedChapters("2", "10", "1"), want: []string{"1", "2", "10"}},
+		{name: "subs", chapters: numberedChapters("12.10", "12.9", "12.2", "12"), want: []string{"12", "12.2", "12.9", "12.10"}},
+		{
+			name:     "unnumbered entries follow the chapter before them",
+			chapters: append([]Chapter{announcement}, append(numberedChapters("3", "2"), append([]Chapter{extra}, numberedChapters("1")...)...)...),
+			want:     []string{"1", extra.URL, "2", "3", announcement.URL},
+		},
+		{
+			name:     "leading unnumbered entry stays first",
+			chapters: append([]Chapter{prologue}, numberedChapters("1", "2")...),
+			want:     []string{prologue.URL, "1", "2"},
+		},
+		{
+			name:     "unnumbered entry moves with its chapter",
+			chapters: append(append(numberedChapters("2"), extra), numberedChapters("1", "3")...),
+			want:     []string{"1", "2", extra.URL, "3"},
+		},
+		{
+			name:     "as listed keeps unnumbered entries",
+			policy:   OrderAsListed,
+			chapters: append(numberedChapters("1"), extra),
+			want:     []string{"1", extra.URL},
+		},
+		{name: "reverse", policy: OrderReverse, chapters: numberedChapters("1", "3", "2"), want: []string{"2", "3", "1"}},
+		{name: "unknown policy", policy: "random", chapters: numberedChapters("1"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ordered, err := orderChapters(slices.Clone(tt.chapters), tt.policy, testChapterNumber)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := chapterLabels(ordered); !slices.Equal(got, tt.want) {
+				t.Errorf("orderChapters = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrderChaptersKeepsDuplicatesInPublishedOrder(t *testing.T) {
+	chapters := numberedChapters("2", "1", "1")
+	chapters[1].Scanlator, chapters[2].Scanlator = "newer", "older"
+
+	ordered, err := orderChapters(chapters, OrderByNumber, testChapterNumber)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var groups []string
+	for _, chapter := range ordered {
+		groups = append(groups, chapter.Scanlator)
+	}
+	if want := []string{"older", "newer", ""}; !slices.Equal(groups, want) {
+		t.Errorf("scanlators in order %v, want %v", groups, want)
+	}
+}
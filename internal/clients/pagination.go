@@ -0,0 +1,68 @@
+package clients
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const defaultMaxPages = 50
+
+// Pagination describes how a listing continues on further pages: either a
+// Next selector for the link to the following page, read from AttrNext or
+// "href", or a URLTemplate such as "{url}?page={page}" that is filled in for
+// page 2, 3 and so on. MaxPages caps the number of pages fetched, including
+// the first one, and Stop is a selector that marks the last page.
+type Pagination struct {
+	Next        string `json:"next"`
+	AttrNext    string `json:"attr_next"`
+	URLTemplate string `json:"url_template"`
+	MaxPages    int    `json:"max_pages"`
+	Stop        string `json:"stop"`
+}
+
+func (p Pagination) enabled() bool {
+	return p.Next != "" || p.URLTemplate != ""
+}
+
+func (p Pagination) maxPages() int {
+	if p.MaxPages > 0 {
+		return p.MaxPages
+	}
+	return defaultMaxPages
+}
+
+// stopsAt reports whether document is the last page.
+func (p Pagination) stopsAt(document *goquery.Document) bool {
+	return p.Stop != "" && document.Find(p.Stop).Length() > 0
+}
+
+// nextPageURL works out the page after document, which was page number page
+// of the listing that starts at baseURL. It returns "" when there is no
+// further page.
+func (p Pagination) nextPageURL(document *goquery.Document, pageURL, baseURL string, page int) string {
+	if p.Next != "" {
+		attr := p.AttrNext
+		if attr == "" {
+			attr = "href"
+		}
+		href, exists := document.Find(p.Next).First().Attr(attr)
+		if !exists || strings.TrimSpace(href) == "" {
+			return ""
+		}
+		next, err := completeURL(strings.TrimSpace(href), pageURL)
+		if err != nil {
+			return ""
+		}
+		return next
+	}
+
+	if p.URLTemplate != "" {
+		return expandTemplate(p.URLTemplate, map[string]string{
+			"url":  strings.TrimSuffix(baseURL, "/"),
+			"page": strconv.Itoa(page + 1),
+		}, nil)
+	}
+	return ""
+}
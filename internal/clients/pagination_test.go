@@ -0,0 +1,258 @@
+package clients
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// testPage is one page of a test site: chapter links, reader images, the
+// next link and whether it carries the stop marker.
+type testPage struct {
+	chapters []string
+	images   []string
+	next     string
+	stop     bool
+}
+
+// serveTestPages serves pages by request URI and answers 404 for the rest.
+func serveTestPages(t *testing.T, pages map[string]testPage) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, ok := pages[r.URL.RequestURI()]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `<ul class="chapters">`)
+		for _, link := range page.chapters {
+			fmt.Fprintf(w, `<li><a href="%s">%s</a></li>`, link, link)
+		}
+		fmt.Fprint(w, `</ul><div class="reader">`)
+		for _, src := range page.images {
+			fmt.Fprintf(w, `<img src="%s">`, src)
+		}
+		fmt.Fprint(w, `</div>`)
+		if page.next != "" {
+			fmt.Fprintf(w, `<a class="next" href="%s">Next</a>`, page.next)
+		}
+		if page.stop {
+			fmt.Fprint(w, `<span class="last"></span>`)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func trimHost(links []string, host string) []string {
+	trimmed := make([]string, len(links))
+	for i, link := range links {
+		trimmed[i] = strings.TrimPrefix(link, host)
+	}
+	return trimmed
+}
+
+func TestCollectLinksPagination(t *testing.T) {
+	tests := []struct {
+		name       string
+		pagination Pagination
+		pages      map[string]testPage
+		want       []string
+	}{
+		{
+			name:       "next links",
+			pagination: Pagination{Next: "a.next"},
+			pages: map[string]testPage{
+				"/series/":         {chapters: []string{"/c/3/"}, next: "/series/?page=2"},
+				"/series/?page=2":  {chapters: []string{"/c/2/"}, next: "/series/?page=3"},
+				"/series/?page=3":  {chapters: []string{"/c/1/"}},
+				"/series/?page=99": {chapters: []string{"/c/0/"}},
+			},
+			want: []string{"/c/3/", "/c/2/", "/c/1/"},
+		},
+		{
+			name:       "url template ends at a missing page",
+			pagination: Pagination{URLTemplate: "{url}/page/{page}"},
+			pages: map[string]testPage{
+				"/series/":        {chapters: []string{"/c/2/"}},
+				"/series/page/2":  {chapters: []string{"/c/1/"}},
+				"/series/page/10": {chapters: []string{"/c/0/"}},
+			},
+			want: []string{"/c/2/", "/c/1/"},
+		},
+		{
+			name:       "page without new chapters",
+			pagination: Pagination{URLTemplate: "{url}/page/{page}"},
+			pages: map[string]testPage{
+				"/series/":       {chapters: []string{"/c/2/", "/c/1/"}},
+				"/series/page/2": {chapters: []string{"/c/2/", "/c/1/"}},
+				"/series/page/3": {chapters: []string{"/c/0/"}},
+			},
+			want: []string{"/c/2/", "/c/1/"},
+		},
+		{
+			name:       "max pages",
+			pagination: Pagination{Next: "a.next", MaxPages: 2},
+			pages: map[string]testPage{
+				"/series/":        {chapters: []string{"/c/3/"}, next: "/series/?page=2"},
+				"/series/?page=2": {chapters: []string{"/c/2/"}, next: "/series/?page=3"},
+				"/series/?page=3": {chapters: []string{"/c/1/"}},
+			},
+			want: []string{"/c/3/", "/c/2/"},
+		},
+		{
+			name:       "stop marker",
+			pagination: Pagination{Next: "a.next", Stop: "span.last"},
+			pages: map[string]testPage{
+				"/series/":        {chapters: []string{"/c/3/"}, next: "/series/?page=2"},
+				"/series/?page=2": {chapters: []string{"/c/2/"}, next: "/series/?page=3", stop: true},
+				"/series/?page=3": {chapters: []string{"/c/1/"}},
+			},
+			want: []string{"/c/3/", "/c/2/"},
+		},
+		{
+			name:       "next link back to the first page",
+			pagination: Pagination{Next: "a.next"},
+			pages: map[string]testPage{
+				"/series/":        {chapters: []string{"/c/2/"}, next: "/series/?page=2"},
+				"/series/?page=2": {chapters: []string{"/c/1/"}, next: "/series/"},
+			},
+			want: []string{"/c/2/", "/c/1/"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := serveTestPages(t, tt.pages)
+			metadata := &ComicMetadata{
+				URL: server.URL + "/series/",
+				ScraperConfig: ScraperConfig{
+					ListChapterURL: "ul.chapters a",
+					AttrChapter:    "href",
+					ChapterPages:   tt.pagination,
+				},
+			}
+			links, err := NewClientRequest(nil).CollectLinks(metadata)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := trimHost(chapterURLs(links), server.URL); !slices.Equal(got, tt.want) {
+				t.Errorf("CollectLinks = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollectImgTagsLinkReaderPages(t *testing.T) {
+	tests := []struct {
+		name       string
+		pagination Pagination
+		pages      map[string]testPage
+		want       []string
+	}{
+		{
+			name:       "next link into the following sub-chapter",
+			pagination: Pagination{Next: "a.next"},
+			pages: map[string]testPage{
+				"/chapter-1/":        {images: []string{"/img/1.jpg"}, next: "/chapter-1/2/"},
+				"/chapter-1/2/":      {images: []string{"/img/2.jpg"}, next: "/chapter-1/?page=3"},
+				"/chapter-1/?page=3": {images: []string{"/img/3.jpg"}, next: "/chapter-1-5/"},
+				"/chapter-1-5/":      {images: []string{"/img/15.jpg"}, next: "/chapter-10/"},
+				"/chapter-10/":       {images: []string{"/img/10.jpg"}},
+			},
+			want: []string{"/img/1.jpg", "/img/2.jpg", "/img/3.jpg"},
+		},
+		{
+			name:       "next link into chapter 10",
+			pagination: Pagination{Next: "a.next"},
+			pages: map[string]testPage{
+				"/chapter-1/":  {images: []string{"/img/1.jpg"}, next: "/chapter-10/"},
+				"/chapter-10/": {images: []string{"/img/10.jpg"}},
+			},
+			want: []string{"/img/1.jpg"},
+		},
+		{
+			name:       "url template",
+			pagination: Pagination{URLTemplate: "{url}/{page}/"},
+			pages: map[string]testPage{
+				"/chapter-1/":   {images: []string{"/img/1.jpg"}},
+				"/chapter-1/2/": {images: []string{"/img/2.jpg"}},
+			},
+			want: []string{"/img/1.jpg", "/img/2.jpg"},
+		},
+		{
+			name:       "stop marker",
+			pagination: Pagination{Next: "a.next", Stop: "span.last"},
+			pages: map[string]testPage{
+				"/chapter-1/":   {images: []string{"/img/1.jpg"}, next: "/chapter-1/2/"},
+				"/chapter-1/2/": {images: []string{"/img/2.jpg"}, next: "/chapter-1/3/", stop: true},
+				"/chapter-1/3/": {images: []string{"/img/3.jpg"}},
+			},
+			want: []string{"/img/1.jpg", "/img/2.jpg"},
+		},
+		{
+			name:       "page without new images",
+			pagination: Pagination{Next: "a.next"},
+			pages: map[string]testPage{
+				"/chapter-1/":   {images: []string{"/img/1.jpg"}, next: "/chapter-1/2/"},
+				"/chapter-1/2/": {images: []string{"/img/1.jpg"}, next: "/chapter-1/3/"},
+				"/chapter-1/3/": {images: []string{"/img/3.jpg"}},
+			},
+			want: []string{"/img/1.jpg"},
+		},
+		{
+			name:       "max pages",
+			pagination: Pagination{Next: "a.next", MaxPages: 2},
+			pages: map[string]testPage{
+				"/chapter-1/":   {images: []string{"/img/1.jpg"}, next: "/chapter-1/2/"},
+				"/chapter-1/2/": {images: []string{"/img/2.jpg"}, next: "/chapter-1/3/"},
+				"/chapter-1/3/": {images: []string{"/img/3.jpg"}},
+			},
+			want: []string{"/img/1.jpg", "/img/2.jpg"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := serveTestPages(t, tt.pages)
+			metadata := &ComicMetadata{
+				URL: server.URL + "/chapter-1/",
+				ScraperConfig: ScraperConfig{
+					ListImageURL: "div.reader img",
+					AttrImage:    "src",
+					ReaderPages:  tt.pagination,
+				},
+			}
+			links, err := NewClientRequest(nil).CollectImgTagsLink(metadata)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := trimHost(links, server.URL); !slices.Equal(got, tt.want) {
+				t.Errorf("CollectImgTagsLink = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithinChapter(t *testing.T) {
+	const chapterURL = "https://example.com/manga/chapter-1"
+	tests := []struct {
+		pageURL string
+		want    bool
+	}{
+		{"https://example.com/manga/chapter-1", true},
+		{"https://example.com/manga/chapter-1/", true},
+		{"https://example.com/manga/chapter-1/2/", true},
+		{"https://example.com/manga/chapter-1?page=2", true},
+		{"https://example.com/manga/chapter-1#p2", true},
+		{"https://example.com/manga/chapter-1-5/", false},
+		{"https://example.com/manga/chapter-10/", false},
+		{"https://example.com/manga/chapter-2/", false},
+	}
+	for _, tt := range tests {
+		if got := withinChapter(tt.pageURL, chapterURL); got != tt.want {
+			t.Errorf("withinChapter(%q) = %v, want %v", tt.pageURL, got, tt.want)
+		}
+	}
+}
@@ -11,13 +11,14 @@ import (
 	"net/url"
 	"regexp"
 	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/disintegration/imaging"
 	"github.com/pwnholic/comdown/internal"
-	"golang.org/x/image/webp"
+	_ "golang.org/x/image/webp"
 	"golang.org/x/net/html/charset"
 	"resty.dev/v3"
 )
@@ -28,7 +29,6 @@ const (
 	defaultRetryMaxWaitTime = 10 * time.Second
 	defaultTimeout          = 30 * time.Second
 	defaultUserAgent        = "Mozilla/5.0 (compatible; Resty Client)"
-	defaultJPEGQuality      = 100
 )
 
 type clientRequest struct {
@@ -98,28 +98,106 @@ func retryHook(r *resty.Response, err error) {
 	}
 }
 
-func (c *clientRequest) CollectLinks(metadata *ComicMetadata) ([]string, error) {
+// CollectLinks returns the chapter list in the order the site lists it.
+// Putting it oldest first is left to the scraper's order policy.
+func (c *clientRequest) CollectLinks(metadata *ComicMetadata) ([]Chapter, error) {
+	if strings.EqualFold(metadata.Mode, ModeAPI) {
+		return c.collectAPILinks(metadata)
+	}
 	if err := validateMetadataForLinks(metadata); err != nil {
 		return nil, err
 	}
 
-	response, err := c.Client.R().Get(metadata.URL)
+	var links []Chapter
+	seen := make(map[string]bool)
+	visited := make(map[string]bool)
+	pagination := metadata.ChapterPages
+
+	pages := 0
+	pageURL := metadata.URL
+	for page := 1; pageURL != "" && !visited[pageURL]; page++ {
+		visited[pageURL] = true
+
+		document, err := c.fetchPage(metadata, pageURL, page)
+		if err != nil {
+			return nil, err
+		}
+		if document == nil {
+			break
+		}
+		if page == 1 {
+			metadata.MovedTo = movedHost(metadata.URL, document.Url)
+			metadata.SeriesPage = document
+		}
+		pages++
+
+		added := 0
+		for _, link := range c.pageLinks(document, metadata, pageURL, page) {
+			if seen[link.URL] {
+				continue
+			}
+			seen[link.URL] = true
+			links = append(links, link)
+			added++
+		}
+
+		if !pagination.enabled() || page >= pagination.maxPages() || pagination.stopsAt(document) {
+			break
+		}
+		// A page without new chapters means the listing wrapped around or
+		// ran past its end.
+		if page > 1 && added == 0 {
+			break
+		}
+		pageURL = pagination.nextPageURL(document, pageURL, metadata.URL, page)
+	}
+
+	if pagination.enabled() {
+		internal.InfoLog("Found %d chapters on %d pages\n", len(links), pages)
+	}
+	return links, nil
+}
+
+// pageLinks reads the chapters of one list page. With a chapter request the
+// list comes from its response, or from the page itself when the request
+// fails or finds nothing, since many sites on a theme with an AJAX endpoint
+// still render the list inline or have the endpoint disabled.
+func (c *clientRequest) pageLinks(document *goquery.Document, metadata *ComicMetadata, pageURL string, page int) []Chapter {
+	if metadata.ChapterRequest.enabled() {
+		listDocument, err := c.fetchChapterRequest(document, metadata, page)
+		if err != nil {
+			internal.WarningLog("Reading chapters off the page instead: %v\n", err)
+			return extractLinks(document, metadata, pageURL)
+		}
+		if links := extractLinks(listDocument, metadata, pageURL); len(links) > 0 {
+			return links
+		}
+		internal.WarningLog("Chapter list request found no chapters, reading them off the page instead\n")
+	}
+	return extractLinks(document, metadata, pageURL)
+}
+
+// fetchPage loads one page of a paginated listing. Only the first page is
+// required, a missing later page returns a nil document and ends the walk.
+func (c *clientRequest) fetchPage(metadata *ComicMetadata, pageURL string, page int) (*goquery.Document, error) {
+	response, err := c.pageRequest(metadata.ScraperConfig).Get(pageURL)
 	if err != nil {
+		if page > 1 {
+			internal.WarningLog("Stopping at page %d: %v\n", page, err)
+			return nil, nil
+		}
 		return nil, fmt.Errorf("failed to fetch URL: %w", err)
 	}
 	defer response.Body.Close()
 
 	checkBlockStatus(response)
 
-	document, err := parseHTMLResponse(response)
-	if err != nil {
-		return nil, err
+	if page > 1 && response.StatusCode() != http.StatusOK {
+		internal.InfoLog("Page %s returned status %d, stopping\n", pageURL, response.StatusCode())
+		return nil, nil
 	}
 
-	links := extractLinks(document, metadata)
-	links = reverseLinks(links)
-
-	return filterLinks(links, metadata), nil
+	return parseHTMLResponse(response)
 }
 
 func validateMetadataForLinks(metadata *ComicMetadata) error {
@@ -140,16 +218,31 @@ func parseHTMLResponse(response *resty.Response) (*goquery.Document, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse HTML document: %w", err)
 	}
+	// Url is where the request ended up after redirects.
+	if response.RawResponse != nil {
+		document.Url = response.RawResponse.Request.URL
+	}
 	return document, nil
 }
 
-func extractLinks(document *goquery.Document, metadata *ComicMetadata) []string {
-	var links []string
+func extractLinks(document *goquery.Document, metadata *ComicMetadata, pageURL string) []Chapter {
+	var links []Chapter
+	numberAttr := ""
+	if strings.EqualFold(metadata.ChapterNumber.Source, NumberFromAttr) {
+		numberAttr = metadata.ChapterNumber.Attr
+	}
 	document.Find(metadata.ListChapterURL).Each(func(i int, s *goquery.Selection) {
 		href, exists := s.Attr(metadata.AttrChapter)
 		if exists {
-			if result, err := completeURL(href, metadata.URL); err == nil {
-				links = append(links, result)
+			if result, err := completeURL(href, pageURL); err == nil {
+				link := Chapter{URL: result, Text: strings.TrimSpace(s.Text())}
+				if numberAttr != "" {
+					link.Attr = s.AttrOr(numberAttr, "")
+				}
+				if metadata.ChapterInfo.enabled() {
+					readChapterInfo(&link, s, metadata.ChapterInfo)
+				}
+				links = append(links, link)
 			} else {
 				internal.ErrorLog("Failed to complete URL: %v\n", err)
 			}
@@ -158,34 +251,14 @@ func extractLinks(document *goquery.Document, metadata *ComicMetadata) []string
 	return links
 }
 
-func reverseLinks(links []string) []string {
-	for i, j := 0, len(links)-1; i < j; i, j = i+1, j-1 {
-		links[i], links[j] = links[j], links[i]
-	}
-	return links
-}
-
-func filterLinks(links []string, metadata *ComicMetadata) []string {
-	isRange := metadata.MinChapter > 0 && metadata.MaxChapter >= metadata.MinChapter
-	isSingle := metadata.Single != 0
-
-	switch {
-	case isRange && !isSingle:
-		internal.InfoLog("Filtering chapters range %d-%d\n", metadata.MinChapter, metadata.MaxChapter)
-		return links[metadata.MinChapter:metadata.MaxChapter]
-	case isSingle && !isRange:
-		internal.InfoLog("Selecting single chapter %d\n", metadata.Single-1)
-		return links[metadata.Single-1 : metadata.Single]
-	default:
-		return links
-	}
-}
-
 func (c *clientRequest) CollectImgTagsLink(metadata *ComicMetadata) ([]string, error) {
+	if strings.EqualFold(metadata.Mode, ModeAPI) {
+		return c.collectAPIImages(metadata)
+	}
 	if err := validateMetadataForImages(metadata); err != nil {
 		return nil, err
 	}
-	response, err := c.Client.R().Get(metadata.URL)
+	response, err := c.pageRequest(metadata.ScraperConfig).Get(metadata.URL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch URL: %w", err)
 	}
@@ -203,39 +276,189 @@ func (c *clientRequest) CollectImgTagsLink(metadata *ComicMetadata) ([]string, e
 		return nil, err
 	}
 
-	return extractImageLinks(document, metadata), nil
+	links := extractImageLinks(document, metadata, metadata.URL)
+	if !metadata.ReaderPages.enabled() {
+		return links, nil
+	}
+	return c.collectReaderPages(document, metadata, links), nil
+}
+
+// collectReaderPages walks the remaining pages of readers that show one page
+// per URL and appends their images in order. The walk ends when there is no
+// next page, a next link leaves the chapter, the stop selector matches, a page
+// adds no new image or the page cap is reached.
+func (c *clientRequest) collectReaderPages(document *goquery.Document, metadata *ComicMetadata, links []string) []string {
+	pagination := metadata.ReaderPages
+	chapterURL := strings.TrimSuffix(metadata.URL, "/")
+
+	seen := make(map[string]bool, len(links))
+	for _, link := range links {
+		seen[link] = true
+	}
+	visited := map[string]bool{metadata.URL: true}
+
+	pages := 1
+	pageURL := metadata.URL
+	for page := 1; page < pagination.maxPages(); page++ {
+		if pagination.stopsAt(document) {
+			break
+		}
+		pageURL = pagination.nextPageURL(document, pageURL, metadata.URL, page)
+		if pageURL == "" || visited[pageURL] {
+			break
+		}
+		if pagination.Next != "" && !withinChapter(pageURL, chapterURL) {
+			internal.InfoLog("Next page %s leaves the chapter, stopping\n", pageURL)
+			break
+		}
+		visited[pageURL] = true
+
+		next, err := c.fetchPage(metadata, pageURL, page+1)
+		if err != nil || next == nil {
+			break
+		}
+		document = next
+		pages++
+
+		added := 0
+		for _, link := range extractImageLinks(document, metadata, pageURL) {
+			if !seen[link] {
+				seen[link] = true
+				links = append(links, link)
+				added++
+			}
+		}
+		if added == 0 {
+			break
+		}
+	}
+
+	internal.InfoLog("Found %d images on %d reader pages of %s\n", len(links), pages, metadata.URL)
+	return links
+}
+
+// withinChapter reports whether pageURL is the chapter page chapterURL,
+// given without its trailing slash, or lies below it. A bare prefix is not
+// enough, chapter-1-5 and chapter-10 are other chapters than chapter-1.
+func withinChapter(pageURL, chapterURL string) bool {
+	rest, ok := strings.CutPrefix(pageURL, chapterURL)
+	return ok && (rest == "" || strings.ContainsAny(rest[:1], "/?#"))
 }
 
 func validateMetadataForImages(metadata *ComicMetadata) error {
-	if len(metadata.ListImageURL) == 0 || (len(metadata.AttrImage) == 0 && len(metadata.Pattern) == 0) || len(metadata.URL) == 0 {
+	if len(metadata.ListImageURL) == 0 || (len(metadata.imageAttrs()) == 0 && len(metadata.Pattern) == 0) || len(metadata.URL) == 0 {
 		return errors.New("metadata conditions not fulfilled for collecting images")
 	}
 	return nil
 }
 
-func extractImageLinks(document *goquery.Document, metadata *ComicMetadata) []string {
-	var links []string
+// extractImageLinks reads the image links of a chapter page in page order.
+// Every link is resolved against the page, and copies inside <noscript> or
+// repeated further down the page are dropped.
+func extractImageLinks(document *goquery.Document, metadata *ComicMetadata, pageURL string) []string {
+	var found []string
+	attrs := metadata.imageAttrs()
 	document.Find(metadata.ListImageURL).Each(func(i int, s *goquery.Selection) {
-		if len(metadata.AttrImage) > 0 && len(metadata.Pattern) == 0 {
-			if href, exists := s.Attr(metadata.AttrImage); exists {
-				links = append(links, href)
-			}
-		} else if len(metadata.Pattern) > 0 {
-			processPatternMatch(s, metadata.Pattern, &links)
+		if s.ParentsFiltered("noscript").Length() > 0 {
+			return
+		}
+		if len(metadata.Pattern) > 0 {
+			processPatternMatch(s, metadata.Pattern, &found)
+			return
+		}
+		if href := imageAttr(s, attrs); href != "" {
+			found = append(found, href)
 		}
 	})
 
-	internal.InfoLog("Found %d images on page %s\n", len(links), metadata.URL)
+	links := make([]string, 0, len(found))
+	seen := make(map[string]bool, len(found))
+	for _, href := range found {
+		link, err := completeURL(href, pageURL)
+		if err != nil {
+			internal.WarningLog("Skipping image link %q: %v\n", href, err)
+			continue
+		}
+		if !seen[link] {
+			seen[link] = true
+			links = append(links, link)
+		}
+	}
+
+	internal.InfoLog("Found %d images on page %s\n", len(links), pageURL)
 	return links
 }
 
+// imageAttr returns the first usable link among attrs. Lazy-load placeholders
+// such as data: URIs are passed over, and srcset attributes give their
+// largest candidate.
+func imageAttr(s *goquery.Selection, attrs []string) string {
+	for _, attr := range attrs {
+		value := cleanImageLink(s.AttrOr(attr, ""))
+		if strings.HasSuffix(strings.ToLower(attr), "srcset") {
+			value = largestSrcsetCandidate(value)
+		}
+		if value != "" && !strings.HasPrefix(value, "data:") {
+			return value
+		}
+	}
+	return ""
+}
+
+// cleanImageLink drops the whitespace and line breaks lazy-load themes leave
+// around and inside attribute values.
+func cleanImageLink(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\r' || r == '\t' {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(value))
+}
+
+// largestSrcsetCandidate picks the widest candidate of a srcset value, or the
+// densest when no candidate gives a width. Widths (w) and densities (x) are
+// not on one scale, so a width always wins over a density. A candidate
+// without descriptor counts as 1x, one with any other descriptor is passed
+// over.
+func largestSrcsetCandidate(srcset string) string {
+	best := map[byte]string{}
+	bestSize := map[byte]float64{}
+	for candidate := range strings.SplitSeq(srcset, ",") {
+		fields := strings.Fields(candidate)
+		if len(fields) == 0 {
+			continue
+		}
+		kind, size := byte('x'), 1.0
+		if len(fields) > 1 {
+			descriptor := strings.ToLower(fields[len(fields)-1])
+			kind = descriptor[len(descriptor)-1]
+			if kind != 'w' && kind != 'x' {
+				continue
+			}
+			v, err := strconv.ParseFloat(descriptor[:len(descriptor)-1], 64)
+			if err != nil || v <= 0 {
+				continue
+			}
+			size = v
+		}
+		if _, ok := best[kind]; !ok || size > bestSize[kind] {
+			best[kind], bestSize[kind] = fields[0], size
+		}
+	}
+	if link, ok := best['w']; ok {
+		return link
+	}
+	return best['x']
+}
+
 func processPatternMatch(s *goquery.Selection, pattern string, links *[]string) {
 	re := regexp.MustCompile(pattern)
 	matches := re.FindStringSubmatch(s.Text())
 	if len(matches) > 1 {
 		images := strings.SplitSeq(matches[1], ",")
 		for imgLink := range images {
-			img := strings.Trim(imgLink, "\" ")
+			img := cleanImageLink(strings.Trim(imgLink, "\" "))
 			img = strings.ReplaceAll(img, "\\/", "/")
 			if isURLValid(img) {
 				*links = append(*links, img)
@@ -249,8 +472,12 @@ func isURLValid(u string) bool {
 	return err == nil
 }
 
-func (c *clientRequest) CollectImage(imgLink string, enhance bool) ([]byte, error) {
-	resp, err := c.Client.R().Get(imgLink)
+// CollectRawImage downloads the image exactly as served, without any
+// conversion. metadata.URL is the chapter the image belongs to, which is
+// where the Referer comes from. A nil slice with a nil error means the server
+// refused it.
+func (c *clientRequest) CollectRawImage(metadata *ComicMetadata, imgLink string) ([]byte, error) {
+	resp, err := c.imageRequest(metadata.ScraperConfig, metadata.URL).Get(imgLink)
 	if err != nil {
 		return nil, fmt.Errorf("failed after %d attempts: %w", resp.Request.Attempt, err)
 	}
@@ -261,11 +488,14 @@ func (c *clientRequest) CollectImage(imgLink string, enhance bool) ([]byte, erro
 		return nil, nil
 	}
 
-	imgBytes, err := readResponseBody(resp)
-	if err != nil {
-		return nil, err
-	}
-	return processImage(imgBytes, enhance)
+	return readResponseBody(resp)
+}
+
+// ConvertImage turns downloaded image bytes into the pages the exporters
+// embed, as selected by mode. A nil slice with a nil error means the image was
+// unusable.
+func (c *clientRequest) ConvertImage(imgBytes []byte, mode ImageMode, enhance bool) ([]byte, error) {
+	return processImage(imgBytes, mode, enhance)
 }
 
 func readResponseBody(resp *resty.Response) ([]byte, error) {
@@ -276,79 +506,67 @@ func readResponseBody(resp *resty.Response) ([]byte, error) {
 	return buff.Bytes(), nil
 }
 
-func processImage(imgBytes []byte, enhance bool) ([]byte, error) {
-	img, format, err := image.Decode(bytes.NewReader(imgBytes))
+// processImage converts downloaded image bytes according to mode. JPEGs are
+// only re-encoded to JPEG when a quality below 100 or enhancement asks for
+// it, and lossless mode only re-encodes what is neither JPEG nor PNG, as PNG.
+// Original mode hands the bytes back untouched, so enhancement does not apply
+// to it.
+func processImage(imgBytes []byte, mode ImageMode, enhance bool) ([]byte, error) {
+	_, format, err := image.DecodeConfig(bytes.NewReader(imgBytes))
 	if err != nil {
 		internal.WarningLog("Failed to decode image: %v\n", err)
 		return nil, nil
 	}
 
 	lowCaseFormat := strings.ToLower(format)
-	if slices.Contains([]string{"gif"}, format) {
-		internal.WarningLog("Skipping gif\n")
+	if lowCaseFormat == "gif" {
+		internal.WarningLog("Only the first frame of gif images is kept\n")
 	}
 
-	switch lowCaseFormat {
-	case "webp":
-		return processWebPImage(imgBytes, enhance)
-	case "png":
-		return processPNGImage(imgBytes, enhance)
-	case "jpg", "jpeg":
+	switch {
+	case mode.Kind == ImageOriginal:
+		return imgBytes, nil
+	case mode.Kind == ImageJPEG && lowCaseFormat == "jpeg" && mode.Quality >= 100 && !enhance:
+		return imgBytes, nil
+	case mode.Kind == ImageLossless && !enhance && slices.Contains([]string{"jpeg", "png"}, lowCaseFormat):
 		return imgBytes, nil
-	default:
-		return encodeToJPEG(img, enhance)
-	}
-}
-
-func processWebPImage(imgBytes []byte, enhance bool) ([]byte, error) {
-	img, err := webp.Decode(bytes.NewReader(imgBytes))
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode webp image: %w", err)
 	}
 
-	jpegBytes, err := encodeToJPEG(img, enhance)
+	img, _, err := image.Decode(bytes.NewReader(imgBytes))
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to decode %s image: %w", lowCaseFormat, err)
 	}
-	return jpegBytes, nil
-}
-
-func processPNGImage(imgBytes []byte, enhance bool) ([]byte, error) {
-	img, err := png.Decode(bytes.NewReader(imgBytes))
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode webp image: %w", err)
+	if enhance {
+		img = enhanceImage(img)
 	}
 
-	jpegBytes, err := encodeToJPEG(img, enhance)
-	if err != nil {
-		return nil, err
+	if mode.Kind == ImageLossless {
+		return encodeToPNG(img)
 	}
-	return jpegBytes, nil
+	return encodeToJPEG(img, mode.Quality)
 }
 
-func encodeToJPEG(img image.Image, enhance bool) ([]byte, error) {
+func encodeToJPEG(img image.Image, quality int) ([]byte, error) {
 	buff := new(bytes.Buffer)
-	if err := jpeg.Encode(buff, img, &jpeg.Options{Quality: defaultJPEGQuality}); err != nil {
+	if err := jpeg.Encode(buff, img, &jpeg.Options{Quality: quality}); err != nil {
 		return nil, fmt.Errorf("failed to encode image: %w", err)
 	}
-	jpgBytes := buff.Bytes()
-	if enhance {
-		return enhanceImage(jpgBytes, enhance)
-	}
-	return jpgBytes, nil
+	return buff.Bytes(), nil
 }
 
-func enhanceImage(imgBytes []byte, enhance bool) ([]byte, error) {
-	img, err := imaging.Decode(bytes.NewReader(imgBytes))
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode image for enhancement: %w", err)
+func encodeToPNG(img image.Image) ([]byte, error) {
+	buff := new(bytes.Buffer)
+	encoder := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := encoder.Encode(buff, img); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
 	}
+	return buff.Bytes(), nil
+}
 
+func enhanceImage(img image.Image) image.Image {
 	img = imaging.Resize(img, img.Bounds().Dx()*2, img.Bounds().Dy()*2, imaging.Lanczos)
 	img = imaging.Sharpen(img, 0.7)
-	img = imaging.AdjustContrast(img, 10)
-
-	return encodeToJPEG(img, enhance)
+	return imaging.AdjustContrast(img, 10)
 }
 
 func checkBlockStatus(response *resty.Response) {
@@ -0,0 +1,209 @@
+package clients
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestProcessImage(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for i := range src.Pix {
+		src.Pix[i] = byte(i * 7)
+	}
+	var jpegBuf, pngBuf bytes.Buffer
+	if err := jpeg.Encode(&jpegBuf, src, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(&pngBuf, src); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		input     []byte
+		mode      ImageMode
+		enhance   bool
+		unchanged bool
+		format    string
+		width     int
+	}{
+		{"jpeg at quality 100", jpegBuf.Bytes(), ImageMode{Kind: ImageJPEG, Quality: 100}, false, true, "jpeg", 16},
+		{"jpeg at lower quality", jpegBuf.Bytes(), ImageMode{Kind: ImageJPEG, Quality: 60}, false, false, "jpeg", 16},
+		{"jpeg enhanced", jpegBuf.Bytes(), ImageMode{Kind: ImageJPEG, Quality: 100}, true, false, "jpeg", 32},
+		{"png to jpeg", pngBuf.Bytes(), ImageMode{Kind: ImageJPEG, Quality: 100}, false, false, "jpeg", 16},
+		{"png lossless", pngBuf.Bytes(), ImageMode{Kind: ImageLossless}, false, true, "png", 16},
+		{"png lossless enhanced", pngBuf.Bytes(), ImageMode{Kind: ImageLossless}, true, false, "png", 32},
+		{"original enhanced", jpegBuf.Bytes(), ImageMode{Kind: ImageOriginal}, true, true, "jpeg", 16},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := processImage(tt.input, tt.mode, tt.enhance)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if unchanged := bytes.Equal(out, tt.input); unchanged != tt.unchanged {
+				t.Errorf("bytes unchanged is %v, want %v", unchanged, tt.unchanged)
+			}
+			cfg, format, err := image.DecodeConfig(bytes.NewReader(out))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if format != tt.format || cfg.Width != tt.width {
+				t.Errorf("got %s %dpx wide, want %s %dpx", format, cfg.Width, tt.format, tt.width)
+			}
+		})
+	}
+}
+
+func TestCollectLinksChapterRequestFallback(t *testing.T) {
+	const inline = `<ul class="version-chap"><li><a href="/series/chapter-2/">2</a></li><li><a href="/series/chapter-1/">1</a></li></ul>`
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   []string
+	}{
+		{name: "request lists chapters", status: http.StatusOK, body: `<ul class="version-chap"><li><a href="/series/chapter-3/">3</a></li></ul>`, want: []string{"/series/chapter-3/"}},
+		{name: "request fails", status: http.StatusNotFound, want: []string{"/series/chapter-2/", "/series/chapter-1/"}},
+		{name: "request finds nothing", status: http.StatusOK, body: `<div class="empty"></div>`, want: []string{"/series/chapter-2/", "/series/chapter-1/"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/series/ajax/chapters/" {
+					w.WriteHeader(tt.status)
+					fmt.Fprint(w, tt.body)
+					return
+				}
+				fmt.Fprint(w, inline)
+			}))
+			defer server.Close()
+
+			metadata := &ComicMetadata{
+				URL: server.URL + "/series/",
+				ScraperConfig: ScraperConfig{
+					ListChapterURL: "ul.version-chap li a",
+					AttrChapter:    "href",
+					ChapterRequest: ChapterRequest{Method: http.MethodPost, URL: "{url}/ajax/chapters/"},
+				},
+			}
+			links, err := NewClientRequest(nil).CollectLinks(metadata)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, link := range links {
+				got = append(got, strings.TrimPrefix(link.URL, server.URL))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("CollectLinks = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLargestSrcsetCandidate(t *testing.T) {
+	tests := []struct {
+		srcset string
+		want   string
+	}{
+		{"", ""},
+		{"a.jpg", "a.jpg"},
+		{"a.jpg 300w, b.jpg 1200w, c.jpg 800w", "b.jpg"},
+		{"a.jpg 1x, b.jpg 2x", "b.jpg"},
+		{"a.jpg, b.jpg 1.5x", "b.jpg"},
+		// Widths win over densities, whatever the numbers.
+		{"a.jpg 3x, b.jpg 400w", "b.jpg"},
+		{"a.jpg 400w, b.jpg 3x", "a.jpg"},
+		// Descriptors that are neither w nor x are passed over.
+		{"a.jpg 100, b.jpg 20w", "b.jpg"},
+		{"a.jpg 100", ""},
+		{"a.jpg 2h, b.jpg 1x", "b.jpg"},
+		{"a.jpg 0w, b.jpg 10w", "b.jpg"},
+		{" a.jpg  640W ,\n b.jpg 320w ", "a.jpg"},
+	}
+	for _, tt := range tests {
+		if got := largestSrcsetCandidate(tt.srcset); got != tt.want {
+			t.Errorf("largestSrcsetCandidate(%q) = %q, want %q", tt.srcset, got, tt.want)
+		}
+	}
+}
+
+func TestImageAttr(t *testing.T) {
+	attrs := []string{"data-src", "data-lazy-src", "srcset", "src"}
+	tests := []struct {
+		img  string
+		want string
+	}{
+		{`<img src="real.jpg">`, "real.jpg"},
+		{`<img src="placeholder.gif" data-src="real.jpg">`, "real.jpg"},
+		{`<img data-lazy-src="lazy.jpg" data-src="first.jpg">`, "first.jpg"},
+		{`<img data-src="data:image/gif;base64,R0lGOD" src="real.jpg">`, "real.jpg"},
+		{`<img data-src="  " srcset="small.jpg 300w, big.jpg 900w" src="real.jpg">`, "big.jpg"},
+		{"<img data-src=\"\n  https://cdn.example.com/\n1.jpg \">", "https://cdn.example.com/1.jpg"},
+		{`<img src="data:image/png;base64,iVBOR">`, ""},
+		{`<img alt="none">`, ""},
+	}
+	for _, tt := range tests {
+		document, err := goquery.NewDocumentFromReader(strings.NewReader(tt.img))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := imageAttr(document.Find("img"), attrs); got != tt.want {
+			t.Errorf("imageAttr(%s) = %q, want %q", tt.img, got, tt.want)
+		}
+	}
+}
+
+func TestExtractImageLinks(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want []string
+	}{
+		{
+			name: "noscript copies are dropped",
+			html: `<div class="reader">
+<img data-src="/img/1.jpg" src="data:image/gif;base64,R0lGOD"><noscript><img src="/img/1.jpg"></noscript>
+<img data-src="/img/2.jpg"><noscript><img src="/img/2-full.jpg"></noscript>
+</div>`,
+			want: []string{"https://example.com/img/1.jpg", "https://example.com/img/2.jpg"},
+		},
+		{
+			name: "repeated links are kept once in page order",
+			html: `<div class="reader"><img src="/img/1.jpg"><img src="2.jpg"><img src="https://example.com/img/1.jpg"></div>`,
+			want: []string{"https://example.com/img/1.jpg", "https://example.com/chapter-1/2.jpg"},
+		},
+		{
+			name: "images without a link are skipped",
+			html: `<div class="reader"><img alt="ad"><img src="/img/1.jpg"></div>`,
+			want: []string{"https://example.com/img/1.jpg"},
+		},
+	}
+	metadata := &ComicMetadata{ScraperConfig: ScraperConfig{
+		ListImageURL: "div.reader img",
+		AttrImages:   []string{"data-src"},
+		AttrImage:    "src",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			document, err := goquery.NewDocumentFromReader(strings.NewReader(tt.html))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := extractImageLinks(document, metadata, "https://example.com/chapter-1/"); !slices.Equal(got, tt.want) {
+				t.Errorf("extractImageLinks = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
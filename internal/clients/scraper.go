@@ -0,0 +1,127 @@
+package clients
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/pwnholic/comdown/internal"
+	"resty.dev/v3"
+)
+
+// Scraper reads one site: the series page, its chapter list, the images of a
+// chapter and the image files themselves. Sites that fit config.json are read
+// by the config-driven scraper, sites with odd logic can register Go code
+// with RegisterScraper instead.
+//
+// Chapters returns every chapter of the series, oldest first, duplicates
+// included: RequestBuilder.ListChapters selects and resolves duplicates for
+// all scrapers alike. Calling Chapters before SeriesInfo lets a scraper read
+// both off one fetch of the series page. FetchImage gets the URL of the
+// chapter (or series, for covers) the image was found on, for sites that
+// check the Referer.
+type Scraper interface {
+	SeriesInfo(seriesURL string) (SeriesInfo, error)
+	Chapters(seriesURL string) ([]Chapter, error)
+	ChapterNumber(link Chapter) (ChapterNumber, error)
+	Pages(chapterURL string) ([]string, error)
+	FetchImage(chapterURL, imgURL string) ([]byte, error)
+}
+
+// ScraperFactory builds a scraper on the shared HTTP client, which already
+// carries the retry and timeout settings.
+type ScraperFactory func(client *resty.Client) Scraper
+
+var scrapers = map[string]ScraperFactory{}
+
+// RegisterScraper makes factory the scraper for hostname, which may be a glob
+// or "re:" pattern like the aliases in config.json. It is meant to be called
+// from init functions, registered scrapers take precedence over config.json.
+func RegisterScraper(hostname string, factory ScraperFactory) {
+	scrapers[hostname] = factory
+}
+
+// lookupScraper finds the registered scraper for a normalised host, preferring
+// an exact hostname over a pattern.
+func lookupScraper(host string) (ScraperFactory, bool) {
+	var found ScraperFactory
+	best := hostNoMatch
+	for _, pattern := range slices.Sorted(maps.Keys(scrapers)) {
+		if match := matchHost(pattern, host); match > best {
+			best, found = match, scrapers[pattern]
+		}
+	}
+	return found, found != nil
+}
+
+// configScraper is the Scraper for sites described in config.json.
+type configScraper struct {
+	config      ScraperConfig
+	request     Request
+	website     Website
+	saveAliases bool
+	// listed is the metadata of the last Chapters call, whose series page
+	// SeriesInfo reads.
+	listed *ComicMetadata
+}
+
+func (s *configScraper) metadata(rawURL string) *ComicMetadata {
+	return &ComicMetadata{URL: rawURL, ScraperConfig: s.config}
+}
+
+func (s *configScraper) SeriesInfo(seriesURL string) (SeriesInfo, error) {
+	metadata := s.metadata(seriesURL)
+	if s.listed != nil && s.listed.URL == seriesURL {
+		metadata.SeriesPage = s.listed.SeriesPage
+	}
+	return s.request.CollectSeriesInfo(metadata)
+}
+
+func (s *configScraper) Chapters(seriesURL string) ([]Chapter, error) {
+	metadata := s.metadata(seriesURL)
+	links, err := s.request.CollectLinks(metadata)
+	if err != nil {
+		return nil, err
+	}
+	s.listed = metadata
+	if metadata.MovedTo != "" {
+		s.offerAlias(metadata.MovedTo)
+	}
+	return orderChapters(links, s.config.Order, s.ChapterNumber)
+}
+
+// DuplicatePolicy is the duplicate policy of the config entry.
+func (s *configScraper) DuplicatePolicy() (DuplicatePolicy, error) {
+	return ParseDuplicatePolicy(s.config.Duplicates)
+}
+
+// offerAlias handles a series page that redirected to a domain the entry does
+// not know yet, which is how these sites announce a move. The alias is saved
+// to config.json when the user allowed it and suggested otherwise.
+func (s *configScraper) offerAlias(host string) {
+	if s.config.matchHost(host) > hostNoMatch {
+		return
+	}
+	if !s.saveAliases {
+		internal.WarningLog("%s redirects to %s, add it to the aliases of %s in config.json or run with -save-alias to record it\n",
+			s.config.Hostname, host, s.config.Hostname)
+		return
+	}
+	if err := s.website.RecordAlias(s.config.Hostname, host); err != nil {
+		internal.ErrorLog("Failed to record alias %s: %v\n", host, err)
+		return
+	}
+	s.config.Aliases = append(s.config.Aliases, host)
+	internal.SuccessLog("Recorded %s as an alias of %s in config.json\n", host, s.config.Hostname)
+}
+
+func (s *configScraper) ChapterNumber(link Chapter) (ChapterNumber, error) {
+	return s.website.GetChapterNumber(link, s.config.ChapterNumber)
+}
+
+func (s *configScraper) Pages(chapterURL string) ([]string, error) {
+	return s.request.CollectImgTagsLink(s.metadata(chapterURL))
+}
+
+func (s *configScraper) FetchImage(chapterURL, imgURL string) ([]byte, error) {
+	return s.request.CollectRawImage(s.metadata(chapterURL), imgURL)
+}
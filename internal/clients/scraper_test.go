@@ -0,0 +1,165 @@
+package clients
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestConfigScraperFetchesSeriesPageOnce(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		fmt.Fprint(w, `<html><body>
+<h1 class="title">Test Series</h1>
+<ul class="chapters">
+<li><a href="/series/chapter-2/">Chapter 2</a></li>
+<li><a href="/series/chapter-1/">Chapter 1</a></li>
+</ul>
+</body></html>`)
+	}))
+	defer server.Close()
+
+	scraper := &configScraper{
+		config: ScraperConfig{
+			ListChapterURL: "ul.chapters a",
+			AttrChapter:    "href",
+			Series:         SeriesSelectors{Title: ValueSelector{Selector: "h1.title"}},
+		},
+		request: NewClientRequest(nil),
+		website: &websiteConfig{},
+	}
+	seriesURL := server.URL + "/series/"
+
+	chapters, err := scraper.Chapters(seriesURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chapters) != 2 {
+		t.Fatalf("got %d chapters, want 2", len(chapters))
+	}
+	info, err := scraper.SeriesInfo(seriesURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Title != "Test Series" {
+		t.Errorf("title = %q, want Test Series", info.Title)
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("series page fetched %d times, want 1", n)
+	}
+}
+
+func TestConfigScraperCountsPagesOfSelectedChapters(t *testing.T) {
+	var mu sync.Mutex
+	fetched := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/series/" {
+			fmt.Fprint(w, `<ul class="chapters">
+<li><a href="/chapter-2/a/">Chapter 2</a></li>
+<li><a href="/chapter-2/b/">Chapter 2</a></li>
+<li><a href="/chapter-1/a/">Chapter 1</a></li>
+<li><a href="/chapter-1/b/">Chapter 1</a></li>
+</ul>`)
+			return
+		}
+		mu.Lock()
+		fetched[r.URL.Path]++
+		mu.Unlock()
+		images := 1
+		if strings.HasSuffix(r.URL.Path, "/b/") {
+			images = 3
+		}
+		for i := range images {
+			fmt.Fprintf(w, `<div class="reader"><img src="/img/%d.jpg"></div>`, i)
+		}
+	}))
+	defer server.Close()
+
+	scraper := &configScraper{
+		config: ScraperConfig{
+			ListChapterURL: "ul.chapters a",
+			AttrChapter:    "href",
+			ListImageURL:   "div.reader img",
+			AttrImage:      "src",
+		},
+		request: NewClientRequest(nil),
+		website: &websiteConfig{},
+	}
+	builder := &RequestBuilder{Duplicates: DuplicatePolicy{DuplicateMostPages}}
+	selection, err := ParseChapterSelection("2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chapters, err := builder.ListChapters(scraper, server.URL+"/series/", selection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chapters) != 1 || !strings.HasSuffix(chapters[0].URL, "/chapter-2/b/") {
+		t.Errorf("chose %v, want the chapter 2 copy with most pages", chapterURLs(chapters))
+	}
+	for path := range fetched {
+		if strings.HasPrefix(path, "/chapter-1/") {
+			t.Errorf("counted the pages of unselected %s", path)
+		}
+	}
+	if len(fetched) != 2 {
+		t.Errorf("fetched %v, want both copies of chapter 2", fetched)
+	}
+}
+
+// listScraper is a Go scraper with a fixed chapter list and page counts.
+type listScraper struct {
+	chapters []Chapter
+	pages    map[string]int
+}
+
+func (s *listScraper) SeriesInfo(string) (SeriesInfo, error)          { return SeriesInfo{}, nil }
+func (s *listScraper) Chapters(string) ([]Chapter, error)             { return slices.Clone(s.chapters), nil }
+func (s *listScraper) ChapterNumber(c Chapter) (ChapterNumber, error) { return testChapterNumber(c) }
+func (s *listScraper) FetchImage(string, string) ([]byte, error)      { return nil, nil }
+
+func (s *listScraper) Pages(chapterURL string) ([]string, error) {
+	return make([]string, s.pages[chapterURL]), nil
+}
+
+func TestListChapters(t *testing.T) {
+	chapters := numberedChapters("1", "2", "2", "3")
+	chapters[1].URL += "#small"
+	chapters[2].URL += "#big"
+	pages := map[string]int{chapters[1].URL: 5, chapters[2].URL: 20}
+	// Chapter 2 is listed twice, the default policy keeps the last copy
+	// and most-pages the bigger one.
+	tests := []struct {
+		name       string
+		expr       string
+		duplicates DuplicatePolicy
+		want       []string
+	}{
+		{name: "default policy", want: []string{chapters[0].URL, chapters[2].URL, chapters[3].URL}},
+		{name: "dup flag", duplicates: DuplicatePolicy{DuplicateFirst}, want: []string{chapters[0].URL, chapters[1].URL, chapters[3].URL}},
+		{name: "selection", expr: "2-", duplicates: DuplicatePolicy{DuplicateMostPages}, want: []string{chapters[2].URL, chapters[3].URL}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selection, err := ParseChapterSelection(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			builder := &RequestBuilder{Duplicates: tt.duplicates}
+			got, err := builder.ListChapters(&listScraper{chapters: chapters, pages: pages}, "https://example.com/series/", selection)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if urls := chapterURLs(got); !slices.Equal(urls, tt.want) {
+				t.Errorf("ListChapters = %v, want %v", urls, tt.want)
+			}
+		})
+	}
+}
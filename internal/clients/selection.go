@@ -0,0 +1,206 @@
+package clients
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pwnholic/comdown/internal"
+)
+
+// ChapterSelection picks chapters by their parsed number. It is written as a
+// comma separated list of terms:
+//
+//	15        chapter 15
+//	20.5      chapter 20.5, which is not 20.50
+//	1-10      chapters 1 to 10, including 10 but not 10.5
+//	100-      chapter 100 and later
+//	-20       up to chapter 20
+//	latest:5  the five highest numbers, "latest" alone is the last one
+//	first:5   the five lowest numbers
+//
+// Numbers compare like ChapterNumber.Compare, so 12.2 lies between 12.1 and
+// 12.10. An empty selection keeps every chapter.
+type ChapterSelection []selectionTerm
+
+type selectionTerm struct {
+	text     string
+	from, to ChapterNumber
+	// openEnd marks ranges like "100-" that have no upper bound.
+	openEnd bool
+	exact   bool
+	latest  int
+	first   int
+}
+
+// ParseChapterSelection parses a selection expression such as
+// "1-10,15,20.5,100-,latest:5".
+func ParseChapterSelection(expr string) (ChapterSelection, error) {
+	var selection ChapterSelection
+	for part := range strings.SplitSeq(expr, ",") {
+		text := strings.ToLower(strings.TrimSpace(part))
+		if text == "" {
+			continue
+		}
+		term, err := parseSelectionTerm(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid chapter selection %q: %w", strings.TrimSpace(part), err)
+		}
+		selection = append(selection, term)
+	}
+	return selection, nil
+}
+
+func parseSelectionTerm(text string) (selectionTerm, error) {
+	term := selectionTerm{text: text}
+
+	for prefix, count := range map[string]*int{"latest": &term.latest, "first": &term.first} {
+		rest, ok := strings.CutPrefix(text, prefix)
+		if !ok {
+			continue
+		}
+		*count = 1
+		if rest != "" {
+			n, err := strconv.Atoi(strings.TrimPrefix(rest, ":"))
+			if err != nil || !strings.HasPrefix(rest, ":") || n < 1 {
+				return term, fmt.Errorf("%s takes a count of at least 1, like %s:5", prefix, prefix)
+			}
+			*count = n
+		}
+		return term, nil
+	}
+
+	from, to, isRange := strings.Cut(text, "-")
+	if !isRange {
+		n, err := parseChapterValue(text)
+		if err != nil {
+			return term, err
+		}
+		term.from, term.to, term.exact = n, n, true
+		return term, nil
+	}
+
+	if from == "" && to == "" {
+		return term, fmt.Errorf("range needs at least one end")
+	}
+	var err error
+	if from != "" {
+		if term.from, err = parseChapterValue(from); err != nil {
+			return term, err
+		}
+	}
+	if term.openEnd = to == ""; !term.openEnd {
+		if term.to, err = parseChapterValue(to); err != nil {
+			return term, err
+		}
+		if term.from.Compare(term.to) > 0 {
+			return term, fmt.Errorf("range starts after it ends")
+		}
+	}
+	return term, nil
+}
+
+// parseChapterValue reads a number such as "20" or "20.5" the way chapter
+// numbers are parsed, with the digits after the dot as Sub.
+func parseChapterValue(text string) (ChapterNumber, error) {
+	text = strings.TrimSpace(text)
+	chapter, sub, hasSub := strings.Cut(text, ".")
+	n, err := strconv.Atoi(chapter)
+	if err != nil || n < 0 || !isDigits(chapter) || (hasSub && !isDigits(sub)) {
+		return ChapterNumber{}, fmt.Errorf("%q is not a chapter number", text)
+	}
+	return ChapterNumber{Chapter: n, Sub: sub}, nil
+}
+
+func isDigits(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
+
+// String renders the selection in the form ParseChapterSelection reads.
+func (s ChapterSelection) String() string {
+	texts := make([]string, len(s))
+	for i, term := range s {
+		texts[i] = term.text
+	}
+	return strings.Join(texts, ",")
+}
+
+// Select keeps the chapters the selection matches, in their listed order.
+// number reads the number of a chapter, chapters whose number cannot be read
+// are skipped. Terms that match nothing are reported in one warning, and an
+// error is only returned when nothing is selected at all.
+func (s ChapterSelection) Select(chapters []Chapter, number func(Chapter) (ChapterNumber, error)) ([]Chapter, error) {
+	if len(s) == 0 {
+		return chapters, nil
+	}
+
+	numbers := make([]ChapterNumber, len(chapters))
+	known := make([]bool, len(chapters))
+	var distinct []ChapterNumber
+	for i, chapter := range chapters {
+		n, err := number(chapter)
+		if err != nil {
+			internal.WarningLog("Skipping chapter %s: %v\n", chapter.URL, err)
+			continue
+		}
+		numbers[i], known[i] = n, true
+		distinct = append(distinct, n)
+	}
+	slices.SortFunc(distinct, ChapterNumber.Compare)
+	distinct = slices.CompactFunc(distinct, func(a, b ChapterNumber) bool { return a.Compare(b) == 0 })
+	picks := func(picked []ChapterNumber) func(ChapterNumber) bool {
+		return func(n ChapterNumber) bool {
+			return slices.ContainsFunc(picked, func(p ChapterNumber) bool { return p.Compare(n) == 0 })
+		}
+	}
+
+	keep := make([]bool, len(chapters))
+	var unmatched []string
+	for _, term := range s {
+		matches := term.matches
+		switch {
+		case term.latest > 0:
+			matches = picks(distinct[max(0, len(distinct)-term.latest):])
+		case term.first > 0:
+			matches = picks(distinct[:min(len(distinct), term.first)])
+		}
+
+		matched := false
+		for i := range chapters {
+			if known[i] && matches(numbers[i]) {
+				keep[i], matched = true, true
+			}
+		}
+		if !matched {
+			unmatched = append(unmatched, term.text)
+		}
+	}
+
+	if len(unmatched) > 0 {
+		available := "none"
+		if len(distinct) > 0 {
+			available = distinct[0].label() + " to " + distinct[len(distinct)-1].label()
+		}
+		internal.WarningLog("No chapter matches %s, the series has chapters %s\n", strings.Join(unmatched, ", "), available)
+	}
+
+	var selected []Chapter
+	for i, chapter := range chapters {
+		if keep[i] {
+			selected = append(selected, chapter)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no chapters match %q", s.String())
+	}
+	internal.InfoLog("Selected %d of %d chapters with %q\n", len(selected), len(chapters), s.String())
+	return selected, nil
+}
+
+func (t selectionTerm) matches(n ChapterNumber) bool {
+	if t.exact {
+		return n.Compare(t.from) == 0
+	}
+	return n.Compare(t.from) >= 0 && (t.openEnd || n.Compare(t.to) <= 0)
+}
@@ -0,0 +1,173 @@
+package clients
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// numberedChapters lists one chapter per number, with URLs in the
+// chapter-N-M form the default URL pattern reads.
+func numberedChapters(numbers ...string) []Chapter {
+	chapters := make([]Chapter, len(numbers))
+	for i, n := range numbers {
+		chapters[i] = Chapter{URL: "https://example.com/chapter-" + strings.ReplaceAll(n, ".", "-") + "/"}
+	}
+	return chapters
+}
+
+var testNumberPattern = regexp.MustCompile(`chapter-(\d+)(?:-(\d+))?`)
+
+// testChapterNumber reads the numbers numberedChapters writes.
+func testChapterNumber(chapter Chapter) (ChapterNumber, error) {
+	match := testNumberPattern.FindStringSubmatch(chapter.URL)
+	if match == nil {
+		return ChapterNumber{}, fmt.Errorf("no chapter number in %s", chapter.URL)
+	}
+	n, _ := strconv.Atoi(match[1])
+	return ChapterNumber{Chapter: n, Sub: match[2]}, nil
+}
+
+func chapterLabels(chapters []Chapter) []string {
+	labels := make([]string, len(chapters))
+	for i, chapter := range chapters {
+		n, err := testChapterNumber(chapter)
+		if err != nil {
+			labels[i] = chapter.URL
+			continue
+		}
+		labels[i] = n.label()
+	}
+	return labels
+}
+
+func TestParseChapterSelection(t *testing.T) {
+	tests := []struct {
+		expr    string
+		want    string
+		wantErr string
+	}{
+		{expr: "", want: ""},
+		{expr: " 1-10, 15 ,20.5,100-,latest:5 ", want: "1-10,15,20.5,100-,latest:5"},
+		{expr: "-20,first:3,LATEST", want: "-20,first:3,latest"},
+		{expr: "10-1", wantErr: "range starts after it ends"},
+		{expr: "12.10-12.9", wantErr: "range starts after it ends"},
+		{expr: "-", wantErr: "range needs at least one end"},
+		{expr: "abc", wantErr: `"abc" is not a chapter number`},
+		{expr: "1-x", wantErr: `"x" is not a chapter number`},
+		{expr: "1e3", wantErr: `"1e3" is not a chapter number`},
+		{expr: "12.", wantErr: `"12." is not a chapter number`},
+		{expr: "latest:0", wantErr: "latest takes a count of at least 1, like latest:5"},
+		{expr: "latest5", wantErr: "latest takes a count of at least 1, like latest:5"},
+		{expr: "first:x", wantErr: "first takes a count of at least 1, like first:5"},
+	}
+	for _, tt := range tests {
+		selection, err := ParseChapterSelection(tt.expr)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseChapterSelection(%q) error = %v, want %q", tt.expr, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseChapterSelection(%q) error = %v", tt.expr, err)
+			continue
+		}
+		if got := selection.String(); got != tt.want {
+			t.Errorf("ParseChapterSelection(%q) = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestChapterSelectionSelect(t *testing.T) {
+	series := numberedChapters("1", "2", "3", "10", "10.5", "11", "15", "20", "20.5", "21", "98", "99", "100", "101")
+	subs := numberedChapters("12", "12.1", "12.2", "12.9", "12.10", "12.11", "13")
+
+	tests := []struct {
+		name     string
+		expr     string
+		chapters []Chapter
+		want     []string
+		wantErr  bool
+	}{
+		{name: "empty keeps all", expr: "", chapters: series[:3], want: []string{"1", "2", "3"}},
+		{name: "single number", expr: "15", chapters: series, want: []string{"15"}},
+		{name: "decimal number", expr: "20.5", chapters: series, want: []string{"20.5"}},
+		{name: "closed range excludes 10.5", expr: "1-10", chapters: series, want: []string{"1", "2", "3", "10"}},
+		{name: "open end", expr: "100-", chapters: series, want: []string{"100", "101"}},
+		{name: "open start", expr: "-3", chapters: series, want: []string{"1", "2", "3"}},
+		{name: "latest", expr: "latest", chapters: series, want: []string{"101"}},
+		{name: "latest count", expr: "latest:3", chapters: series, want: []string{"99", "100", "101"}},
+		{name: "first count", expr: "first:2", chapters: series, want: []string{"1", "2"}},
+		{name: "latest beyond length", expr: "latest:50", chapters: series[:2], want: []string{"1", "2"}},
+		{
+			name: "combined terms keep listed order", expr: "1-10,15,20.5,100-,latest:5", chapters: series,
+			want: []string{"1", "2", "3", "10", "15", "20.5", "21", "98", "99", "100", "101"},
+		},
+		{name: "unmatched term is only a warning", expr: "15,500", chapters: series, want: []string{"15"}},
+		{name: "nothing matches", expr: "500-", chapters: series, wantErr: true},
+
+		// Subs compare as whole numbers, 12.1 and 12.10 are different
+		// chapters and 12.9 comes before 12.10.
+		{name: "sub 1", expr: "12.1", chapters: subs, want: []string{"12.1"}},
+		{name: "sub 10", expr: "12.10", chapters: subs, want: []string{"12.10"}},
+		{name: "sub range", expr: "12.2-12.10", chapters: subs, want: []string{"12.2", "12.9", "12.10"}},
+		{name: "whole chapter range", expr: "12-12", chapters: subs, want: []string{"12"}},
+		{name: "latest with subs", expr: "latest:2", chapters: subs, want: []string{"12.11", "13"}},
+		{name: "first with subs", expr: "first:3", chapters: subs, want: []string{"12", "12.1", "12.2"}},
+
+		{
+			name: "unnumbered chapters are skipped", expr: "1-",
+			chapters: append(numberedChapters("1"), Chapter{URL: "https://example.com/announcement/"}),
+			want:     []string{"1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selection, err := ParseChapterSelection(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			selected, err := selection.Select(tt.chapters, testChapterNumber)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Select(%q) = %v, want an error", tt.expr, chapterLabels(selected))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := chapterLabels(selected); !slices.Equal(got, tt.want) {
+				t.Errorf("Select(%q) = %v, want %v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChapterNumberCompare(t *testing.T) {
+	tests := []struct {
+		a, b ChapterNumber
+		want int
+	}{
+		{ChapterNumber{Chapter: 12}, ChapterNumber{Chapter: 12}, 0},
+		{ChapterNumber{Chapter: 12}, ChapterNumber{Chapter: 13}, -1},
+		{ChapterNumber{Chapter: 12}, ChapterNumber{Chapter: 12, Sub: "1"}, -1},
+		{ChapterNumber{Chapter: 12, Sub: "2"}, ChapterNumber{Chapter: 12, Sub: "10"}, -1},
+		{ChapterNumber{Chapter: 12, Sub: "10"}, ChapterNumber{Chapter: 12, Sub: "1"}, 1},
+		{ChapterNumber{Chapter: 12, Sub: "5"}, ChapterNumber{Chapter: 12, Sub: "5", Volume: "2"}, 0},
+		{ChapterNumber{Chapter: 12, Sub: "05"}, ChapterNumber{Chapter: 12, Sub: "5"}, -1},
+		{ChapterNumber{Chapter: 12, Sub: "a"}, ChapterNumber{Chapter: 12, Sub: "b"}, -1},
+	}
+	for _, tt := range tests {
+		if got := tt.a.Compare(tt.b); got != tt.want {
+			t.Errorf("%v.Compare(%v) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := tt.b.Compare(tt.a); got != -tt.want {
+			t.Errorf("%v.Compare(%v) = %d, want %d", tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package clients
+
+import (
+	"encoding/json"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/pwnholic/comdown/internal"
+)
+
+// Series statuses. Status texts the sites use, in English and Indonesian, are
+// mapped onto these and anything else is kept as written.
+const (
+	StatusOngoing   = "Ongoing"
+	StatusCompleted = "Completed"
+	StatusHiatus    = "Hiatus"
+	StatusCancelled = "Cancelled"
+)
+
+// SeriesInfo describes a series as its site presents it. Every field is
+// optional, sites only fill in what their config or scraper can find.
+type SeriesInfo struct {
+	Title     string
+	AltTitles []string
+	Authors   []string
+	Artists   []string
+	Genres    []string
+	Status    string
+	Synopsis  string
+	CoverURL  string
+	// Language comes from the site config rather than the page.
+	Language string
+}
+
+// SeriesSelectors read the series details off the chapter list page. Each one
+// is a ValueSelector or, for the common case, just its CSS selector. The list
+// fields take every match and split each on commas, so both one link per
+// genre and a single "A, B" text work.
+type SeriesSelectors struct {
+	Title     ValueSelector `json:"title"`
+	AltTitles ValueSelector `json:"alt_titles"`
+	Authors   ValueSelector `json:"authors"`
+	Artists   ValueSelector `json:"artists"`
+	Genres    ValueSelector `json:"genres"`
+	Status    ValueSelector `json:"status"`
+	Synopsis  ValueSelector `json:"synopsis"`
+}
+
+func (s SeriesSelectors) enabled() bool {
+	return s != SeriesSelectors{}
+}
+
+var statusWords = map[string][]string{
+	StatusOngoing:   {"ongoing", "on going", "berjalan", "publishing", "releasing"},
+	StatusCompleted: {"completed", "complete", "tamat", "selesai", "finished", "end"},
+	StatusHiatus:    {"hiatus", "on hold"},
+	StatusCancelled: {"cancelled", "canceled", "dropped", "discontinued"},
+}
+
+// CollectSeriesInfo reads the series details and cover link from the chapter
+// list page, reusing metadata.SeriesPage when CollectLinks already fetched
+// it. Sites without series selectors or a cover selector are not fetched at
+// all and only get their configured language.
+func (c *clientRequest) CollectSeriesInfo(metadata *ComicMetadata) (SeriesInfo, error) {
+	if !metadata.Series.enabled() && metadata.CoverImage == "" {
+		return SeriesInfo{Language: metadata.Language}, nil
+	}
+
+	document := metadata.SeriesPage
+	if document == nil {
+		var err error
+		if document, err = c.fetchPage(metadata, metadata.URL, 1); err != nil {
+			return SeriesInfo{}, err
+		}
+	}
+
+	selectors := metadata.Series
+	info := SeriesInfo{
+		Title:     firstValue(selectors.Title.values(document.Selection)),
+		AltTitles: splitValues(selectors.AltTitles.values(document.Selection)),
+		Authors:   splitValues(selectors.Authors.values(document.Selection)),
+		Artists:   splitValues(selectors.Artists.values(document.Selection)),
+		Genres:    splitValues(selectors.Genres.values(document.Selection)),
+		Status:    normalizeStatus(firstValue(selectors.Status.values(document.Selection))),
+		Synopsis:  strings.Join(selectors.Synopsis.values(document.Selection), "\n\n"),
+		Language:  metadata.Language,
+	}
+
+	if metadata.CoverImage != "" {
+		attr := metadata.AttrCover
+		if attr == "" {
+			attr = "src"
+		}
+		href, exists := document.Find(metadata.CoverImage).First().Attr(attr)
+		if !exists || strings.TrimSpace(href) == "" {
+			internal.WarningLog("No cover image found on page %s\n", metadata.URL)
+		} else {
+			var err error
+			if info.CoverURL, err = completeURL(strings.TrimSpace(href), metadata.URL); err != nil {
+				return info, err
+			}
+		}
+	}
+	return info, nil
+}
+
+// UnmarshalJSON accepts a plain string as a selector without attribute or
+// pattern.
+func (v *ValueSelector) UnmarshalJSON(data []byte) error {
+	var selector string
+	if err := json.Unmarshal(data, &selector); err == nil {
+		*v = ValueSelector{Selector: selector}
+		return nil
+	}
+	type plain ValueSelector
+	return json.Unmarshal(data, (*plain)(v))
+}
+
+// values returns the text or Attr of every element below root that Selector
+// matches, narrowed to the first group of Pattern. Unlike extract, finding
+// nothing is not an error. Runs of whitespace within a line are collapsed.
+func (v ValueSelector) values(root *goquery.Selection) []string {
+	if v.Selector == "" {
+		return nil
+	}
+	var re *regexp.Regexp
+	if v.Pattern != "" {
+		var err error
+		if re, err = regexp.Compile(v.Pattern); err != nil {
+			internal.WarningLog("Invalid pattern %q: %v\n", v.Pattern, err)
+			return nil
+		}
+	}
+
+	var values []string
+	root.Find(v.Selector).Each(func(_ int, s *goquery.Selection) {
+		value := s.Text()
+		if v.Attr != "" {
+			value = s.AttrOr(v.Attr, "")
+		}
+		if re != nil {
+			match := re.FindStringSubmatch(value)
+			if len(match) < 2 {
+				return
+			}
+			value = match[1]
+		}
+		lines := strings.Split(value, "\n")
+		for i, line := range lines {
+			lines[i] = strings.Join(strings.Fields(line), " ")
+		}
+		if value = strings.TrimSpace(strings.Join(slices.DeleteFunc(lines, func(line string) bool { return line == "" }), "\n")); value != "" {
+			values = append(values, value)
+		}
+	})
+	return values
+}
+
+func firstValue(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return strings.Join(strings.Fields(values[0]), " ")
+}
+
+// splitValues splits every value on commas and semicolons and drops empty and
+// repeated entries.
+func splitValues(values []string) []string {
+	var items []string
+	for _, value := range values {
+		for _, item := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ';' || r == '\n' }) {
+			item = strings.TrimSpace(item)
+			if item != "" && item != "-" && !slices.Contains(items, item) {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
+
+// normalizeStatus maps a status text such as "Berjalan" or "Completed" onto
+// one of the Status constants.
+func normalizeStatus(status string) string {
+	words := " " + strings.Join(strings.FieldsFunc(strings.ToLower(status), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}), " ") + " "
+	for _, normalized := range []string{StatusCancelled, StatusHiatus, StatusCompleted, StatusOngoing} {
+		for _, word := range statusWords[normalized] {
+			if strings.Contains(words, " "+word+" ") {
+				return normalized
+			}
+		}
+	}
+	return status
+}
@@ -1,36 +1,238 @@
 package clients
 
 import (
+	"cmp"
+	_ "embed"
 	"encoding/json"
 	"fmt"
+	"maps"
 	"net/url"
 	"os"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/PuerkitoBio/goquery"
 	"github.com/pwnholic/comdown/internal"
 )
 
 type ComicMetadata struct {
-	MaxChapter int
-	MinChapter int
-	URL        string
-	Single     int
+	URL string
+	// MovedTo is set by CollectLinks to the host the series page redirected
+	// to when it left the requested domain.
+	MovedTo string
+	// SeriesPage is the series page CollectLinks fetched, so
+	// CollectSeriesInfo can read it without fetching it again.
+	SeriesPage *goquery.Document
 	ScraperConfig
 }
 
 type ScraperConfig struct {
-	Hostname       string `json:"hostname"`
+	Hostname string `json:"hostname"`
+	// Aliases are other hostnames of the site, such as old domains and
+	// numbered mirrors. Like Hostname they may be globs ("komikcast*.com")
+	// or regular expressions prefixed with "re:". A "www." prefix and IDN
+	// spelling never need an alias of their own.
+	Aliases []string `json:"aliases"`
+	// Extends names the preset the entry starts from, see presets.json.
+	Extends        string `json:"extends"`
 	ListChapterURL string `json:"list_chapter_url"`
 	AttrChapter    string `json:"attr_chapter"`
 	ListImageURL   string `json:"list_image_url"`
 	AttrImage      string `json:"attr_image"`
-	Pattern        string `json:"pattern"`
+	// AttrImages lists image attributes in priority order, for lazy-load
+	// themes that keep the real link in data-src and the like. AttrImage is
+	// tried after them.
+	AttrImages []string `json:"attr_images"`
+	Pattern    string   `json:"pattern"`
+	// CoverImage selects the series cover on the chapter list page, read
+	// from AttrCover or "src" when that is empty.
+	CoverImage string `json:"cover_image"`
+	AttrCover  string `json:"attr_cover"`
+	// Series reads the title, authors, genres and the like off the same
+	// page.
+	Series SeriesSelectors `json:"series"`
+	// ChapterNumber overrides where chapter numbers are read from.
+	ChapterNumber ChapterNumberRule `json:"chapter_number"`
+	// Order is how the chapter list is put oldest first: "by-number" (the
+	// default), "as-listed" or "reverse".
+	Order string `json:"order"`
+	// Duplicates picks between copies of a chapter listed more than once,
+	// see DuplicatePolicy. It defaults to the newest copy.
+	Duplicates string `json:"duplicates"`
+	// ChapterInfo reads chapter titles, release dates and groups from the
+	// chapter list.
+	ChapterInfo ChapterInfo `json:"chapter_info"`
+	// ChapterPages follows chapter lists that are split over several pages.
+	ChapterPages Pagination `json:"chapter_pages"`
+	// ChapterRequest loads the chapter list with a second request when the
+	// series page does not contain it.
+	ChapterRequest ChapterRequest `json:"chapter_request"`
+	// ReaderPages walks chapter readers that show one image per page.
+	ReaderPages Pagination `json:"reader_pages"`
+	// Mode "api" reads the chapter and image lists from the JSON endpoints
+	// in API instead of the selectors above.
+	Mode string    `json:"mode"`
+	API  APIConfig `json:"api"`
+	// Headers and Cookies are sent with every request to the site, for age
+	// gates, language choices and the like. Image requests add ImageHeaders
+	// and a Referer: "chapter" for the chapter URL, "origin" for its scheme
+	// and host, or a fixed URL.
+	Headers      map[string]string `json:"headers"`
+	Cookies      map[string]string `json:"cookies"`
+	ImageHeaders map[string]string `json:"image_headers"`
+	Referer      string            `json:"referer"`
+	// Language is the BCP 47 tag of the site's translations, such as "id",
+	// written into the exported books.
+	Language string `json:"language"`
+}
+
+const (
+	NumberFromURL  = "url"
+	NumberFromText = "text"
+	NumberFromAttr = "attr"
+
+	defaultURLNumberPattern = `chapter-(\d+)(?:-(\d+))?`
+	defaultNumberPattern    = `(\d+)(?:[.-](\d+))?`
+)
+
+var (
+	defaultURLNumberRegexp = regexp.MustCompile(defaultURLNumberPattern)
+	defaultNumberRegexp    = regexp.MustCompile(defaultNumberPattern)
+)
+
+// ChapterNumberRule says where a site keeps its chapter numbers: in the
+// chapter URL (the default), the link text or an attribute of the link. An
+// empty pattern matches chapter-N-M in URLs and N.M anywhere else.
+type ChapterNumberRule struct {
+	Source  string `json:"source"`
+	Attr    string `json:"attr"`
+	Pattern string `json:"pattern"`
+	// compiled is Pattern compiled when config.json was loaded.
+	compiled *regexp.Regexp
+}
+
+// compile checks the rule and compiles its pattern once, so a bad pattern
+// shows when config.json loads and chapters are not matched against a
+// freshly compiled one each.
+func (r *ChapterNumberRule) compile() error {
+	switch strings.ToLower(r.Source) {
+	case "", NumberFromURL, NumberFromText, NumberFromAttr:
+	default:
+		return fmt.Errorf("unknown chapter number source %q, use url, text or attr", r.Source)
+	}
+	if r.Pattern == "" {
+		return nil
+	}
+	re, err := regexp.Compile(r.Pattern)
+	if err != nil {
+		return fmt.Errorf("invalid chapter number pattern: %w", err)
+	}
+	r.compiled = re
+	return nil
+}
+
+// pattern is the compiled pattern of the rule, or the default for its
+// source when it has none.
+func (r ChapterNumberRule) pattern() (*regexp.Regexp, error) {
+	switch {
+	case r.compiled != nil:
+		return r.compiled, nil
+	case r.Pattern != "":
+		// Rules built in code rather than read from config.json.
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid chapter number pattern: %w", err)
+		}
+		return re, nil
+	case r.Source == "" || strings.EqualFold(r.Source, NumberFromURL):
+		return defaultURLNumberRegexp, nil
+	default:
+		return defaultNumberRegexp, nil
+	}
+}
+
+// Chapter is one entry of a series chapter list. Attr holds the value of
+// the attribute the chapter number rule reads, if it reads one. Title, Date
+// and Scanlator are only set when the site config reads them, Date is the
+// zero time when the release date is unknown. Variant tells copies of the
+// same chapter apart when a duplicate policy keeps all of them.
+type Chapter struct {
+	URL       string
+	Text      string
+	Attr      string
+	Title     string
+	Date      time.Time
+	Scanlator string
+	Variant   string
+}
+
+// ChapterNumber is a parsed chapter number. Sub is the part after the dot of
+// chapters like 12.5 and Volume is only set when the site shows it.
+type ChapterNumber struct {
+	Chapter int
+	Sub     string
+	Volume  string
+}
+
+// String formats the number the way output files are named, e.g. "07" or
+// "12.5".
+func (n ChapterNumber) String() string {
+	if n.Sub != "" {
+		return fmt.Sprintf("%02d.%s", n.Chapter, n.Sub)
+	}
+	return fmt.Sprintf("%02d", n.Chapter)
+}
+
+// label formats the number without padding for messages, e.g. "7" or
+// "12.5".
+func (n ChapterNumber) label() string {
+	if n.Sub != "" {
+		return fmt.Sprintf("%d.%s", n.Chapter, n.Sub)
+	}
+	return strconv.Itoa(n.Chapter)
+}
+
+// Compare orders chapter numbers by chapter and then by sub as a whole
+// number, so 12 comes before 12.2 and 12.2 before 12.10. Subs that are not
+// numbers, or numbers written differently like "05" and "5", are told apart
+// as text. The volume is not compared.
+func (n ChapterNumber) Compare(other ChapterNumber) int {
+	if c := cmp.Compare(n.Chapter, other.Chapter); c != 0 {
+		return c
+	}
+	switch {
+	case n.Sub == other.Sub:
+		return 0
+	case n.Sub == "":
+		return -1
+	case other.Sub == "":
+		return 1
+	}
+	a, errA := strconv.Atoi(n.Sub)
+	b, errB := strconv.Atoi(other.Sub)
+	if errA == nil && errB == nil && a != b {
+		return cmp.Compare(a, b)
+	}
+	return strings.Compare(n.Sub, other.Sub)
+}
+
+// imageAttrs is the order image attributes are tried in.
+func (s ScraperConfig) imageAttrs() []string {
+	attrs := slices.Clone(s.AttrImages)
+	if s.AttrImage != "" && !slices.Contains(attrs, s.AttrImage) {
+		attrs = append(attrs, s.AttrImage)
+	}
+	return attrs
 }
 
 type websiteConfig struct {
 	configPath string
+	// mu serialises RecordAlias, which rewrites config.json.
+	mu sync.Mutex
 }
 
 func NewWebsiteConfig() *websiteConfig {
@@ -46,15 +248,9 @@ func NewWebsiteConfig() *websiteConfig {
 }
 
 func (c *websiteConfig) GetHTMLTagAttrFromURL(rawURL string) *ScraperConfig {
-	configFile, err := os.ReadFile(c.configPath)
+	config, err := loadSiteConfigs(c.configPath)
 	if err != nil {
-		internal.ErrorLog("Failed to read configuration file: %s\n", err.Error())
-		return nil
-	}
-
-	var config []ScraperConfig
-	if err := json.Unmarshal(configFile, &config); err != nil {
-		internal.ErrorLog("Failed to parse JSON configuration: %s\n", err.Error())
+		internal.ErrorLog("%s\n", err.Error())
 		return nil
 	}
 
@@ -65,29 +261,165 @@ func (c *websiteConfig) GetHTMLTagAttrFromURL(rawURL string) *ScraperConfig {
 	}
 
 	host := parsedURL.Hostname()
-	for _, site := range config {
-		if strings.EqualFold(host, site.Hostname) {
-			internal.InfoLog("Found configuration for domain: %s\n", host)
-			return &site
+	normalized := normalizeHost(host)
+	var found *ScraperConfig
+	best := hostNoMatch
+	for i := range config {
+		if match := config[i].matchHost(normalized); match > best {
+			best, found = match, &config[i]
 		}
 	}
+	if found != nil {
+		internal.InfoLog("Found configuration %s for domain: %s\n", found.Hostname, host)
+		return found
+	}
 	internal.WarningLog("Configuration not found for domain: '%s'\n", host)
 	return nil
 }
 
-func (w *websiteConfig) GetChapterNumber(urlRaw string) (string, error) {
-	re := regexp.MustCompile(`chapter-(\d+)(?:-(\d+))?`)
-	match := re.FindStringSubmatch(urlRaw)
-	if match == nil {
-		return "", fmt.Errorf("number not found")
+// loadSiteConfigs reads config.json with every entry's preset applied. An
+// entry that cannot be read, such as one extending an unknown preset, is
+// skipped with a warning so the other sites keep working.
+func loadSiteConfigs(configPath string) ([]ScraperConfig, error) {
+	configFile, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read configuration file: %w", err)
+	}
+
+	var entries []map[string]json.RawMessage
+	if err := json.Unmarshal(configFile, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON configuration: %w", err)
+	}
+
+	configs := make([]ScraperConfig, 0, len(entries))
+	for i, entry := range entries {
+		config, err := readSiteConfig(entry)
+		if err != nil {
+			name := fmt.Sprintf("entry %d", i+1)
+			var hostname string
+			if json.Unmarshal(entry["hostname"], &hostname) == nil && hostname != "" {
+				name = hostname
+			}
+			internal.WarningLog("Skipping %s in config.json: %v\n", name, err)
+			continue
+		}
+		configs = append(configs, config)
+	}
+	return configs, nil
+}
+
+// readSiteConfig turns one entry of config.json into a site config.
+func readSiteConfig(entry map[string]json.RawMessage) (ScraperConfig, error) {
+	fields, err := applyPreset(entry, nil)
+	if err != nil {
+		return ScraperConfig{}, err
+	}
+	merged, err := json.Marshal(fields)
+	if err != nil {
+		return ScraperConfig{}, fmt.Errorf("failed to merge site configuration: %w", err)
+	}
+	var config ScraperConfig
+	if err := json.Unmarshal(merged, &config); err != nil {
+		return ScraperConfig{}, fmt.Errorf("failed to parse JSON configuration: %w", err)
+	}
+	if err := config.ChapterNumber.compile(); err != nil {
+		return ScraperConfig{}, err
+	}
+	return config, nil
+}
+
+// applyPreset fills in the fields entry does not set from the preset it
+// extends, following presets that extend other presets. Fields are replaced
+// whole, an entry that sets chapter_request replaces the preset's request.
+func applyPreset(entry map[string]json.RawMessage, seen []string) (map[string]json.RawMessage, error) {
+	rawName, ok := entry["extends"]
+	if !ok {
+		return entry, nil
+	}
+	var name string
+	if err := json.Unmarshal(rawName, &name); err != nil {
+		return nil, fmt.Errorf("invalid extends value %s: %w", rawName, err)
+	}
+	if slices.Contains(seen, name) {
+		return nil, fmt.Errorf("preset %q extends itself", name)
 	}
 
-	mainNum, _ := strconv.Atoi(match[1])
-	mainFormatted := fmt.Sprintf("%02d", mainNum)
+	presets, err := loadPresets()
+	if err != nil {
+		return nil, err
+	}
+	preset, ok := presets[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown preset %q, choose from %s", name, strings.Join(slices.Sorted(maps.Keys(presets)), ", "))
+	}
+
+	base, err := applyPreset(maps.Clone(preset), append(seen, name))
+	if err != nil {
+		return nil, err
+	}
+	delete(base, "extends")
+	merged := maps.Clone(base)
+	maps.Copy(merged, entry)
+	return merged, nil
+}
+
+//go:embed presets.json
+var presetsJSON []byte
+
+// loadPresets parses the theme presets shipped with the binary. Site entries
+// pick one with "extends" and override what differs.
+var loadPresets = sync.OnceValues(func() (map[string]map[string]json.RawMessage, error) {
+	var presets map[string]map[string]json.RawMessage
+	if err := json.Unmarshal(presetsJSON, &presets); err != nil {
+		return nil, fmt.Errorf("failed to parse presets: %w", err)
+	}
+	return presets, nil
+})
 
-	if match[2] != "" {
-		return fmt.Sprintf("%s.%s", mainFormatted, match[2]), nil
+func (w *websiteConfig) GetChapterNumber(link Chapter, rule ChapterNumberRule) (ChapterNumber, error) {
+	var value string
+	switch strings.ToLower(rule.Source) {
+	case "", NumberFromURL:
+		value = link.URL
+	case NumberFromText:
+		value = link.Text
+	case NumberFromAttr:
+		value = link.Attr
+	default:
+		return ChapterNumber{}, fmt.Errorf("unknown chapter number source %q, use url, text or attr", rule.Source)
 	}
 
-	return mainFormatted, nil
+	re, err := rule.pattern()
+	if err != nil {
+		return ChapterNumber{}, err
+	}
+	match := re.FindStringSubmatch(value)
+	if match == nil {
+		return ChapterNumber{}, fmt.Errorf("number not found in %q", value)
+	}
+
+	named := re.SubexpIndex("chapter") > 0
+	group := func(name string, index int) string {
+		switch {
+		case named && re.SubexpIndex(name) > 0:
+			return match[re.SubexpIndex(name)]
+		case !named && index < len(match):
+			return match[index]
+		case !named && index == 1:
+			// A pattern without groups matches the number itself.
+			return match[0]
+		default:
+			return ""
+		}
+	}
+
+	mainNum, err := strconv.Atoi(group("chapter", 1))
+	if err != nil {
+		return ChapterNumber{}, fmt.Errorf("number not found in %q", value)
+	}
+	return ChapterNumber{
+		Chapter: mainNum,
+		Sub:     group("sub", 2),
+		Volume:  group("volume", 3),
+	}, nil
 }
@@ -0,0 +1,251 @@
+package clients
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestGetChapterNumber(t *testing.T) {
+	tests := []struct {
+		name    string
+		link    Chapter
+		rule    ChapterNumberRule
+		want    ChapterNumber
+		wantErr string
+	}{
+		{name: "default url", link: Chapter{URL: "https://example.com/one-piece-chapter-12/"}, want: ChapterNumber{Chapter: 12}},
+		{name: "default url sub", link: Chapter{URL: "https://example.com/chapter-12-5/"}, want: ChapterNumber{Chapter: 12, Sub: "5"}},
+		{name: "default text", link: Chapter{Text: "Chapter 12.10"}, rule: ChapterNumberRule{Source: NumberFromText}, want: ChapterNumber{Chapter: 12, Sub: "10"}},
+		{name: "default attr", link: Chapter{Attr: "7"}, rule: ChapterNumberRule{Source: "ATTR", Attr: "data-num"}, want: ChapterNumber{Chapter: 7}},
+		{
+			name: "positional groups",
+			link: Chapter{URL: "https://example.com/read/12_3/v4"},
+			rule: ChapterNumberRule{Pattern: `read/(\d+)_(\d+)/v(\d+)`},
+			want: ChapterNumber{Chapter: 12, Sub: "3", Volume: "4"},
+		},
+		{
+			name: "positional without sub",
+			link: Chapter{URL: "https://example.com/read/12"},
+			rule: ChapterNumberRule{Pattern: `read/(\d+)`},
+			want: ChapterNumber{Chapter: 12},
+		},
+		{
+			name: "pattern without groups",
+			link: Chapter{Text: "Ep 42"},
+			rule: ChapterNumberRule{Source: NumberFromText, Pattern: `\d+`},
+			want: ChapterNumber{Chapter: 42},
+		},
+		{
+			name: "named groups in any order",
+			link: Chapter{Text: "Vol.3 Ch.25.5"},
+			rule: ChapterNumberRule{Source: NumberFromText, Pattern: `Vol\.(?P<volume>\d+) Ch\.(?P<chapter>\d+)(?:\.(?P<sub>\d+))?`},
+			want: ChapterNumber{Chapter: 25, Sub: "5", Volume: "3"},
+		},
+		{
+			name: "named groups without volume",
+			link: Chapter{Text: "Ch.25"},
+			rule: ChapterNumberRule{Source: NumberFromText, Pattern: `Ch\.(?P<chapter>\d+)(?:\.(?P<sub>\d+))?`},
+			want: ChapterNumber{Chapter: 25},
+		},
+		{
+			name:    "number not found",
+			link:    Chapter{URL: "https://example.com/announcement/"},
+			wantErr: "number not found",
+		},
+		{
+			name:    "group that is not a number",
+			link:    Chapter{Text: "Chapter One"},
+			rule:    ChapterNumberRule{Source: NumberFromText, Pattern: `Chapter (\w+)`},
+			wantErr: "number not found",
+		},
+		{
+			name:    "empty attr",
+			link:    Chapter{URL: "https://example.com/chapter-3/"},
+			rule:    ChapterNumberRule{Source: NumberFromAttr, Attr: "data-num"},
+			wantErr: "number not found",
+		},
+		{name: "unknown source", rule: ChapterNumberRule{Source: "title"}, wantErr: "unknown chapter number source"},
+		{name: "invalid pattern", rule: ChapterNumberRule{Pattern: `(\d+`}, wantErr: "invalid chapter number pattern"},
+	}
+	w := &websiteConfig{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Rules read from config.json come compiled, the others are
+			// compiled on the fly, both must read the same.
+			compiled := tt.rule
+			if err := compiled.compile(); err != nil {
+				compiled = tt.rule
+			}
+			for _, rule := range []ChapterNumberRule{tt.rule, compiled} {
+				got, err := w.GetChapterNumber(tt.link, rule)
+				if tt.wantErr != "" {
+					if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+						t.Errorf("error = %v, want %q", err, tt.wantErr)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != tt.want {
+					t.Errorf("GetChapterNumber = %+v, want %+v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func writeTestConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadSiteConfigsCompilesChapterNumberPattern(t *testing.T) {
+	configs, err := loadSiteConfigs(writeTestConfig(t, `[
+  {"hostname": "a.com", "chapter_number": {"source": "text", "pattern": "Ch\\.(\\d+)"}},
+  {"hostname": "b.com"}
+]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if configs[0].ChapterNumber.compiled == nil {
+		t.Error("pattern of a.com was not compiled")
+	}
+	if configs[1].ChapterNumber.compiled != nil {
+		t.Error("b.com without a pattern got one compiled")
+	}
+
+	// A bad pattern skips its entry only.
+	configs, err = loadSiteConfigs(writeTestConfig(t, `[{"hostname": "a.com", "chapter_number": {"pattern": "(\\d+"}}, {"hostname": "b.com"}]`))
+	if err != nil || len(configs) != 1 || configs[0].Hostname != "b.com" {
+		t.Errorf("bad pattern loaded %v, %v, want only b.com", configs, err)
+	}
+}
+
+// usePresets swaps the shipped presets for the test.
+func usePresets(t *testing.T, presets string) {
+	t.Helper()
+	var parsed map[string]map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(presets), &parsed); err != nil {
+		t.Fatal(err)
+	}
+	shipped := loadPresets
+	loadPresets = func() (map[string]map[string]json.RawMessage, error) { return parsed, nil }
+	t.Cleanup(func() { loadPresets = shipped })
+}
+
+func TestLoadSiteConfigsPresets(t *testing.T) {
+	usePresets(t, `{
+  "base": {
+    "list_chapter_url": "ul.base a",
+    "attr_chapter": "href",
+    "list_image_url": "div.base img",
+    "chapter_request": { "url": "{url}/ajax/", "form": { "action": "list" } },
+    "series": { "title": "h1.base", "authors": "span.author" }
+  },
+  "child": { "extends": "base", "list_image_url": "div.child img", "order": "reverse" },
+  "loop-a": { "extends": "loop-b" },
+  "loop-b": { "extends": "loop-a" }
+}`)
+	configs, err := loadSiteConfigs(writeTestConfig(t, `[
+  {"hostname": "plain.com", "list_chapter_url": "ul a"},
+  {"hostname": "base.com", "extends": "base", "attr_chapter": "data-href"},
+  {"hostname": "nested.com", "extends": "child"},
+  {"hostname": "override.com", "extends": "base",
+   "chapter_request": { "url": "{origin}/other/" }, "series": { "title": "h2" }},
+  {"hostname": "unknown.com", "extends": "missing"},
+  {"hostname": "loop.com", "extends": "loop-a"},
+  {"hostname": "pattern.com", "chapter_number": {"pattern": "(\\d+"}},
+  {"hostname": "last.com", "extends": "base"}
+]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byHost := make(map[string]ScraperConfig)
+	var hosts []string
+	for _, config := range configs {
+		byHost[config.Hostname] = config
+		hosts = append(hosts, config.Hostname)
+	}
+	// Broken entries are skipped, the ones around them still load.
+	if want := []string{"plain.com", "base.com", "nested.com", "override.com", "last.com"}; !slices.Equal(hosts, want) {
+		t.Fatalf("loaded %v, want %v", hosts, want)
+	}
+
+	tests := []struct {
+		host  string
+		field string
+		got   string
+		want  string
+	}{
+		{"plain.com", "list_chapter_url", byHost["plain.com"].ListChapterURL, "ul a"},
+		{"plain.com", "attr_chapter", byHost["plain.com"].AttrChapter, ""},
+		{"base.com", "list_chapter_url", byHost["base.com"].ListChapterURL, "ul.base a"},
+		{"base.com", "attr_chapter", byHost["base.com"].AttrChapter, "data-href"},
+		{"base.com", "extends", byHost["base.com"].Extends, "base"},
+		{"nested.com", "list_chapter_url", byHost["nested.com"].ListChapterURL, "ul.base a"},
+		{"nested.com", "list_image_url", byHost["nested.com"].ListImageURL, "div.child img"},
+		{"nested.com", "order", byHost["nested.com"].Order, "reverse"},
+		{"nested.com", "extends", byHost["nested.com"].Extends, "child"},
+		// Fields are replaced whole, nothing of the preset's object is kept.
+		{"override.com", "chapter_request.url", byHost["override.com"].ChapterRequest.URL, "{origin}/other/"},
+		{"override.com", "chapter_request.form", fmt.Sprint(byHost["override.com"].ChapterRequest.Form), "map[]"},
+		{"override.com", "series.title", byHost["override.com"].Series.Title.Selector, "h2"},
+		{"override.com", "series.authors", byHost["override.com"].Series.Authors.Selector, ""},
+		{"override.com", "list_image_url", byHost["override.com"].ListImageURL, "div.base img"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s %s = %q, want %q", tt.host, tt.field, tt.got, tt.want)
+		}
+	}
+}
+
+func TestApplyPresetErrors(t *testing.T) {
+	usePresets(t, `{"self": {"extends": "self"}, "base": {"attr_chapter": "href"}}`)
+	tests := []struct {
+		entry   string
+		wantErr string
+	}{
+		{`{"extends": "missing"}`, `unknown preset "missing", choose from base, self`},
+		{`{"extends": "self"}`, `preset "self" extends itself`},
+		{`{"extends": 3}`, "invalid extends value 3"},
+	}
+	for _, tt := range tests {
+		var entry map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(tt.entry), &entry); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := applyPreset(entry, nil); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("applyPreset(%s) error = %v, want %q", tt.entry, err, tt.wantErr)
+		}
+	}
+}
+
+func TestShippedPresetsLoad(t *testing.T) {
+	presets, err := loadPresets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name := range presets {
+		entry := map[string]json.RawMessage{"hostname": json.RawMessage(`"example.com"`), "extends": json.RawMessage(`"` + name + `"`)}
+		config, err := readSiteConfig(entry)
+		if err != nil {
+			t.Errorf("preset %s: %v", name, err)
+			continue
+		}
+		if config.ListChapterURL == "" || config.ListImageURL == "" {
+			t.Errorf("preset %s lacks chapter or image selectors", name)
+		}
+	}
+}
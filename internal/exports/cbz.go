@@ -0,0 +1,165 @@
+package exports
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"image"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pwnholic/comdown/internal"
+)
+
+// CBZGenerator streams pages into a zip archive as they are added.
+type CBZGenerator struct {
+	spool    *zipSpool
+	pages    int
+	metadata Metadata
+	mutex    sync.Mutex
+}
+
+func init() {
+	RegisterFormat(Format{
+		Name:      "cbz",
+		Extension: "cbz",
+		Original:  true,
+		New:       func(Options) Exporter { return NewCBZGenerator() },
+	})
+}
+
+func NewCBZGenerator() *CBZGenerator {
+	return &CBZGenerator{}
+}
+
+// SetMetadata fills the ComicInfo.xml written next to the pages, the format
+// Komga, Kavita and Mihon read series and chapter data from.
+func (c *CBZGenerator) SetMetadata(meta Metadata) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.metadata = meta
+}
+
+// AddChapter is a no-op, CBZ archives have no navigation structure.
+func (c *CBZGenerator) AddChapter(title string) {}
+
+// AddImage stores the image as the next page of the archive. The bytes are
+// kept as they are, only the format is sniffed to name the entry.
+func (c *CBZGenerator) AddImage(imgBytes []byte, fileName, rawURL string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if len(imgBytes) == 0 {
+		internal.WarningLog("Skipping empty image data\n")
+		return nil
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(imgBytes))
+	if err != nil {
+		internal.WarningLog("Failed to decode image: %v\n", err)
+		return nil
+	}
+
+	if c.spool == nil {
+		if c.spool, err = createZipSpool(fileName); err != nil {
+			return err
+		}
+	}
+
+	c.pages++
+	name := pageName(c.pages) + "." + imageExtension(format)
+	if err := c.spool.add(name, zip.Store, imgBytes); err != nil {
+		return err
+	}
+	logImageInfo(format, cfg.Width, cfg.Height, rawURL, fileName)
+	return nil
+}
+
+func (c *CBZGenerator) Save(outputPath string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.spool == nil {
+		return errors.New("CBZ has no pages")
+	}
+
+	if !c.metadata.IsZero() {
+		if err := c.spool.add("ComicInfo.xml", zip.Deflate, c.comicInfo()); err != nil {
+			return err
+		}
+	}
+
+	if err := c.spool.commit(outputPath); err != nil {
+		return err
+	}
+	c.spool = nil
+	return nil
+}
+
+func (c *CBZGenerator) Reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.spool.discard()
+	c.spool = nil
+	c.pages = 0
+	c.metadata = Metadata{}
+}
+
+func (c *CBZGenerator) comicInfo() []byte {
+	meta := c.metadata
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString("<ComicInfo xmlns:xsi=\"http://www.w3.org/2001/XMLSchema-instance\" xmlns:xsd=\"http://www.w3.org/2001/XMLSchema\">\n")
+	title := meta.Title()
+	if meta.ChapterTitle != "" {
+		title = meta.ChapterTitle
+	}
+	writeXMLElement(&b, "Title", title)
+	writeXMLElement(&b, "Series", meta.Series)
+	if len(meta.AltTitles) > 0 {
+		writeXMLElement(&b, "LocalizedSeries", meta.AltTitles[0])
+	}
+	writeXMLElement(&b, "Number", meta.Chapter)
+	writeXMLElement(&b, "Volume", meta.Volume)
+	writeXMLElement(&b, "Summary", meta.Description())
+	writeXMLElement(&b, "Writer", strings.Join(meta.Authors, ", "))
+	writeXMLElement(&b, "Penciller", strings.Join(meta.Artists, ", "))
+	writeXMLElement(&b, "Translator", meta.Scanlator)
+	writeXMLElement(&b, "Genre", strings.Join(meta.Genres, ", "))
+	writeXMLElement(&b, "Web", meta.SourceURL)
+	date := meta.Date
+	if !meta.Released.IsZero() {
+		date = meta.Released
+	}
+	if !date.IsZero() {
+		writeXMLElement(&b, "Year", strconv.Itoa(date.Year()))
+		writeXMLElement(&b, "Month", strconv.Itoa(int(date.Month())))
+		writeXMLElement(&b, "Day", strconv.Itoa(date.Day()))
+	}
+	writeXMLElement(&b, "PageCount", strconv.Itoa(c.pages))
+	writeXMLElement(&b, "LanguageISO", meta.Language)
+	b.WriteString("</ComicInfo>\n")
+	return []byte(b.String())
+}
+
+func writeXMLElement(b *strings.Builder, name, value string) {
+	if value == "" {
+		return
+	}
+	fmt.Fprintf(b, "  <%s>%s</%s>\n", name, html.EscapeString(value), name)
+}
+
+func imageExtension(format string) string {
+	switch strings.ToLower(format) {
+	case "jpeg", "jpg":
+		return "jpg"
+	case "":
+		return "img"
+	default:
+		return strings.ToLower(format)
+	}
+}
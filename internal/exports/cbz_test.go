@@ -0,0 +1,50 @@
+package exports
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestComicInfoSeriesNames(t *testing.T) {
+	tests := []struct {
+		name     string
+		meta     Metadata
+		want     []string
+		unwanted []string
+	}{
+		{
+			name:     "no other titles",
+			meta:     Metadata{Series: "Solo Leveling", Chapter: "12"},
+			want:     []string{"<Series>Solo Leveling</Series>"},
+			unwanted: []string{"<LocalizedSeries>"},
+		},
+		{
+			name: "other titles",
+			meta: Metadata{Series: "Solo Leveling", Chapter: "12", AltTitles: []string{"Na Honjaman Level Up", "나 혼자만 레벨업"}},
+			want: []string{"<Series>Solo Leveling</Series>", "<LocalizedSeries>Na Honjaman Level Up</LocalizedSeries>"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCBZGenerator()
+			c.SetMetadata(tt.meta)
+			info := string(c.comicInfo())
+			for _, want := range tt.want {
+				if !strings.Contains(info, want) {
+					t.Errorf("ComicInfo lacks %s", want)
+				}
+			}
+			for _, unwanted := range tt.unwanted {
+				if strings.Contains(info, unwanted) {
+					t.Errorf("ComicInfo has %s", unwanted)
+				}
+			}
+			keywords := tt.meta.Keywords()
+			for _, alt := range tt.meta.AltTitles {
+				if !strings.Contains(strings.Join(keywords, "\n"), alt) {
+					t.Errorf("keywords lack %q", alt)
+				}
+			}
+		})
+	}
+}
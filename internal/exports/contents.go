@@ -0,0 +1,237 @@
+package exports
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/pwnholic/comdown/internal"
+)
+
+const (
+	contentsMinMargin  = 48
+	contentsTitleSize  = 22
+	contentsLabelSize  = 13
+	contentsEntrySize  = 11
+	contentsLineHeight = 18
+	// contentsCoverShare is how much of the page height the cover may take.
+	contentsCoverShare = 0.45
+)
+
+// contentsEntry is one line of the table of contents.
+type contentsEntry struct {
+	title  string
+	pageID int
+	page   int
+}
+
+// addContentsPages writes the pages that open a document with chapters: the
+// series cover and title, the chapter range and a table of contents linking
+// to the first page of every chapter. They are put in front of the pages
+// already written, running over several pages when the chapters do not fit
+// on one.
+func (w *pdfWriter) addContentsPages(meta Metadata, layout PageLayout) error {
+	font, err := loadPDFFont()
+	if err != nil {
+		return err
+	}
+	f := font.subset()
+
+	width, height := layout.Width, layout.Height
+	if width == 0 || height == 0 {
+		width, height = pageSizes["a4"][0], pageSizes["a4"][1]
+	}
+	margin := max(layout.Margin, contentsMinMargin)
+
+	coverID, coverW, coverH := w.writeCover(meta.Cover, width-2*margin, (height-2*margin)*contentsCoverShare)
+
+	// The heading block sits below the cover on the first page only.
+	headingHeight := coverH + contentsTitleSize*1.6 + contentsLabelSize*1.6 + contentsLineHeight*1.5
+	if coverH > 0 {
+		headingHeight += contentsLineHeight
+	}
+	firstLines := max(1, int((height-2*margin-headingHeight)/contentsLineHeight))
+	otherLines := max(1, int((height-2*margin)/contentsLineHeight))
+
+	pageCount := 1
+	if rest := len(w.outline) - firstLines; rest > 0 {
+		pageCount += (rest + otherLines - 1) / otherLines
+	}
+
+	pageNumbers := make(map[int]int, len(w.pages))
+	for i, id := range w.pages {
+		pageNumbers[id] = pageCount + i + 1
+	}
+	entries := make([]contentsEntry, len(w.outline))
+	for i, entry := range w.outline {
+		entries[i] = contentsEntry{title: entry.title, pageID: entry.pageID, page: pageNumbers[entry.pageID]}
+	}
+
+	// The font is written once the pages show which glyphs it needs.
+	fontID := w.newObject()
+
+	pageIDs := make([]int, 0, pageCount)
+	for n := range pageCount {
+		var content strings.Builder
+		if layout.Mode != LayoutNative {
+			bg := layout.Background
+			fmt.Fprintf(&content, "q %.3f %.3f %.3f rg 0 0 %.2f %.2f re f Q\n",
+				float64(bg.R)/255, float64(bg.G)/255, float64(bg.B)/255, width, height)
+		}
+
+		y := height - margin
+		lines := otherLines
+		if n == 0 {
+			lines = firstLines
+			if coverID > 0 {
+				y -= coverH
+				fmt.Fprintf(&content, "q %.2f 0 0 %.2f %.2f %.2f cm /Cover Do Q\n", coverW, coverH, (width-coverW)/2, y)
+				y -= contentsLineHeight
+			}
+
+			title := contentsTitle(font, meta)
+			y -= contentsTitleSize * 1.2
+			writeCentredText(&content, f, f.fit(title, contentsTitleSize, width-2*margin), contentsTitleSize, width, y)
+			y -= contentsLabelSize * 1.6
+			writeCentredText(&content, f, meta.chapterLabel(), contentsLabelSize, width, y)
+			y -= contentsLineHeight * 1.5
+		}
+
+		start := 0
+		if n > 0 {
+			start = firstLines + (n-1)*otherLines
+		}
+		end := min(start+lines, len(entries))
+
+		var annots []string
+		for _, entry := range entries[start:end] {
+			y -= contentsLineHeight
+			annots = append(annots, writeContentsEntry(&content, f, entry, margin, width-margin, y))
+		}
+
+		pageID, err := w.writeContentsPage(content.String(), annots, fontID, coverID, width, height)
+		if err != nil {
+			return err
+		}
+		pageIDs = append(pageIDs, pageID)
+	}
+
+	if err := w.writeFont(f, fontID); err != nil {
+		return err
+	}
+	w.pages = append(pageIDs, w.pages...)
+	return nil
+}
+
+// writeCover embeds the cover image scaled into maxW x maxH points and returns
+// its id and drawn size. A missing or unreadable cover is left out.
+func (w *pdfWriter) writeCover(cover []byte, maxW, maxH float64) (int, float64, float64) {
+	if len(cover) == 0 {
+		return 0, 0, 0
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(cover))
+	if err != nil {
+		internal.WarningLog("Failed to decode cover image: %v\n", err)
+		return 0, 0, 0
+	}
+
+	var id int
+	if format == "jpeg" {
+		if cover, cfg, err = limitImageSize(cover, cfg); err == nil {
+			id, err = w.writeJPEGImage(cover, cfg)
+		}
+	} else {
+		var img image.Image
+		if img, _, err = image.Decode(bytes.NewReader(cover)); err == nil {
+			img = limitPixelSize(img)
+			cfg.Width, cfg.Height = img.Bounds().Dx(), img.Bounds().Dy()
+			id, err = w.writePixelImage(img)
+		}
+	}
+	if err != nil {
+		internal.WarningLog("Failed to embed cover image: %v\n", err)
+		return 0, 0, 0
+	}
+
+	scale := min(maxW/float64(cfg.Width), maxH/float64(cfg.Height))
+	return id, float64(cfg.Width) * scale, float64(cfg.Height) * scale
+}
+
+// contentsTitle is the series title, or the last segment of the series URL
+// when the font cannot show the title, e.g. for Japanese or Korean titles.
+func contentsTitle(f *pdfFont, meta Metadata) string {
+	title := meta.Series
+	if title == "" {
+		title = meta.Title()
+	}
+	if f.covers(title) {
+		return title
+	}
+	if u, err := url.Parse(meta.SourceURL); err == nil {
+		if slug := path.Base(strings.TrimSuffix(u.Path, "/")); slug != "." && slug != "/" && f.covers(slug) {
+			return slug
+		}
+	}
+	return f.drawable(title)
+}
+
+func writeCentredText(content *strings.Builder, f *pdfFontSubset, text string, size, pageWidth, y float64) {
+	if text == "" {
+		return
+	}
+	x := (pageWidth - f.width(text, size)) / 2
+	fmt.Fprintf(content, "BT /F1 %d Tf %.2f %.2f Td %s Tj ET\n", int(size), x, y, f.show(text))
+}
+
+// writeContentsEntry draws the chapter title on the left, its page number on
+// the right and dot leaders between them, and returns the link annotation
+// that makes the line jump to the chapter.
+func writeContentsEntry(content *strings.Builder, f *pdfFontSubset, entry contentsEntry, left, right, y float64) string {
+	number := fmt.Sprint(entry.page)
+	numberWidth := f.width(number, contentsEntrySize)
+	dotWidth := f.width(".", contentsEntrySize)
+
+	title := f.fit(f.drawable(entry.title), contentsEntrySize, right-left-numberWidth-4*dotWidth)
+	titleWidth := f.width(title, contentsEntrySize)
+	dots := int((right - left - titleWidth - numberWidth) / dotWidth)
+
+	fmt.Fprintf(content, "BT /F1 %d Tf %.2f %.2f Td %s Tj ET\n", contentsEntrySize, left, y, f.show(title))
+	if dots > 2 {
+		leader := strings.Repeat(".", dots-2)
+		fmt.Fprintf(content, "q 0.6 g BT /F1 %d Tf %.2f %.2f Td %s Tj ET Q\n",
+			contentsEntrySize, right-numberWidth-dotWidth-f.width(leader, contentsEntrySize), y, f.show(leader))
+	}
+	fmt.Fprintf(content, "BT /F1 %d Tf %.2f %.2f Td %s Tj ET\n", contentsEntrySize, right-numberWidth, y, f.show(number))
+
+	return fmt.Sprintf("<< /Type /Annot /Subtype /Link /Rect [%.2f %.2f %.2f %.2f] /Border [0 0 0] /Dest [%d 0 R /Fit] >>",
+		left, y-contentsLineHeight*0.3, right, y+contentsLineHeight*0.7, entry.pageID)
+}
+
+func (w *pdfWriter) writeContentsPage(content string, annots []string, fontID, coverID int, width, height float64) (int, error) {
+	contentID := w.newObject()
+	if err := w.writeStream(contentID, "", []byte(content)); err != nil {
+		return 0, err
+	}
+
+	var annotRefs strings.Builder
+	for _, annot := range annots {
+		id := w.newObject()
+		if err := w.writeObject(id, annot); err != nil {
+			return 0, err
+		}
+		fmt.Fprintf(&annotRefs, "%d 0 R ", id)
+	}
+
+	resources := fmt.Sprintf("/Font << /F1 %d 0 R >>", fontID)
+	if coverID > 0 {
+		resources += fmt.Sprintf(" /XObject << /Cover %d 0 R >>", coverID)
+	}
+	pageID := w.newObject()
+	page := fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %.2f %.2f] /Resources << %s >> /Contents %d 0 R /Annots [%s] >>",
+		pdfPagesID, width, height, resources, contentID, strings.TrimSpace(annotRefs.String()))
+	return pageID, w.writeObject(pageID, page)
+}
@@ -0,0 +1,129 @@
+package exports
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pwnholic/comdown/internal"
+)
+
+// DirGenerator writes the downloaded images into a plain folder, one file per
+// page, for readers and scripts that work on loose images. Pages go into a
+// hidden folder next to the output that is renamed into place on Save, with
+// a marker file that tells a finished folder from one left by an older or
+// interrupted run.
+type DirGenerator struct {
+	spoolDir string
+	pages    int
+	mutex    sync.Mutex
+}
+
+// dirCompleteMarker is written into an image folder once every page is in.
+const dirCompleteMarker = ".comdown-complete"
+
+func init() {
+	RegisterFormat(Format{
+		Name:     "dir",
+		Original: true,
+		New:      func(Options) Exporter { return NewDirGenerator() },
+	})
+}
+
+func NewDirGenerator() *DirGenerator {
+	return &DirGenerator{}
+}
+
+// SetMetadata is a no-op, image folders carry no metadata.
+func (d *DirGenerator) SetMetadata(meta Metadata) {}
+
+// AddChapter is a no-op, image folders have no navigation structure.
+func (d *DirGenerator) AddChapter(title string) {}
+
+func (d *DirGenerator) AddImage(imgBytes []byte, fileName, rawURL string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if len(imgBytes) == 0 {
+		internal.WarningLog("Skipping empty image data\n")
+		return nil
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(imgBytes))
+	if err != nil {
+		internal.WarningLog("Failed to decode image: %v\n", err)
+		return nil
+	}
+
+	if d.spoolDir == "" {
+		parent := filepath.Dir(fileName)
+		if err := os.MkdirAll(parent, os.ModePerm); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+		if d.spoolDir, err = os.MkdirTemp(parent, ".comdown-*.part"); err != nil {
+			return fmt.Errorf("failed to create image folder: %w", err)
+		}
+	}
+
+	d.pages++
+	name := filepath.Join(d.spoolDir, pageName(d.pages)+"."+imageExtension(format))
+	if err := os.WriteFile(name, imgBytes, 0o644); err != nil {
+		return fmt.Errorf("failed to write image %s: %w", name, err)
+	}
+
+	logImageInfo(format, cfg.Width, cfg.Height, rawURL, fileName)
+	return nil
+}
+
+func (d *DirGenerator) Save(outputPath string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.spoolDir == "" {
+		return errors.New("image folder has no pages")
+	}
+
+	if err := os.WriteFile(filepath.Join(d.spoolDir, dirCompleteMarker), nil, 0o644); err != nil {
+		return fmt.Errorf("failed to mark image folder complete: %w", err)
+	}
+	if err := os.Chmod(d.spoolDir, 0o755); err != nil {
+		return fmt.Errorf("failed to set image folder permissions: %w", err)
+	}
+	// A leftover folder without the marker is unfinished and would block the
+	// rename.
+	if _, err := os.Stat(outputPath); err == nil {
+		if DirComplete(outputPath) {
+			return fmt.Errorf("image folder %s already exists", outputPath)
+		}
+		internal.WarningLog("Replacing unfinished image folder %s\n", outputPath)
+		if err := os.RemoveAll(outputPath); err != nil {
+			return fmt.Errorf("failed to remove unfinished image folder: %w", err)
+		}
+	}
+	if err := os.Rename(d.spoolDir, outputPath); err != nil {
+		return fmt.Errorf("failed to move image folder into place: %w", err)
+	}
+	d.spoolDir = ""
+	return nil
+}
+
+// DirComplete reports whether path is an image folder Save finished.
+func DirComplete(path string) bool {
+	_, err := os.Stat(filepath.Join(path, dirCompleteMarker))
+	return err == nil
+}
+
+func (d *DirGenerator) Reset() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.spoolDir != "" {
+		_ = os.RemoveAll(d.spoolDir)
+	}
+	d.spoolDir = ""
+	d.pages = 0
+}
@@ -0,0 +1,38 @@
+package exports
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDirSaveReplacesUnfinishedFolder(t *testing.T) {
+	outputPath := filepath.Join(t.TempDir(), "01")
+	if err := os.MkdirAll(outputPath, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(outputPath, "0001.jpg"), []byte("partial"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if DirComplete(outputPath) {
+		t.Fatal("folder without marker reported complete")
+	}
+
+	d := NewDirGenerator()
+	if err := d.AddImage(testPNG(t, 4, 6), outputPath, "https://example.com/1.png"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Save(outputPath); err != nil {
+		t.Fatal(err)
+	}
+
+	if !DirComplete(outputPath) {
+		t.Fatal("saved folder is not marked complete")
+	}
+	if _, err := os.Stat(filepath.Join(outputPath, "0001.png")); err != nil {
+		t.Errorf("page missing from saved folder: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputPath, "0001.jpg")); err == nil {
+		t.Error("leftover page from the unfinished folder was kept")
+	}
+}
@@ -0,0 +1,373 @@
+package exports
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"html"
+	"image"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pwnholic/comdown/internal"
+)
+
+const (
+	DirectionLTR = "ltr"
+	DirectionRTL = "rtl"
+)
+
+type epubPage struct {
+	ext           string
+	width, height int
+}
+
+type epubChapter struct {
+	title string
+	start int
+}
+
+// EPUBGenerator builds an EPUB3 fixed-layout book with one XHTML page per
+// image, sized to the image so readers can scale it to the screen. Images
+// are streamed into the archive as they arrive, the package document and
+// navigation are written on Save.
+type EPUBGenerator struct {
+	spool          *zipSpool
+	pages          []epubPage
+	chapters       []epubChapter
+	pendingChapter string
+	metadata       Metadata
+	direction      string
+	mutex          sync.Mutex
+}
+
+func init() {
+	RegisterFormat(Format{
+		Name:      "epub",
+		Extension: "epub",
+		New:       func(opts Options) Exporter { return NewEPUBGenerator(opts.Direction) },
+	})
+}
+
+func NewEPUBGenerator(direction string) *EPUBGenerator {
+	if direction != DirectionRTL {
+		direction = DirectionLTR
+	}
+	return &EPUBGenerator{direction: direction}
+}
+
+func (e *EPUBGenerator) SetMetadata(meta Metadata) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.metadata = meta
+}
+
+// AddChapter adds a table of contents entry pointing at the next page.
+func (e *EPUBGenerator) AddChapter(title string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.pendingChapter = title
+}
+
+func (e *EPUBGenerator) AddImage(imgBytes []byte, fileName, rawURL string) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if len(imgBytes) == 0 {
+		internal.WarningLog("Skipping empty image data\n")
+		return nil
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(imgBytes))
+	if err != nil {
+		internal.WarningLog("Failed to decode image: %v\n", err)
+		return nil
+	}
+
+	if _, ok := epubMediaTypes[imageExtension(format)]; !ok {
+		internal.WarningLog("Skipping unsupported image format: %s (only jpg/png/gif/webp allowed)\n", format)
+		return nil
+	}
+
+	if e.spool == nil {
+		if err := e.startArchive(fileName); err != nil {
+			return err
+		}
+	}
+
+	page := epubPage{ext: imageExtension(format), width: cfg.Width, height: cfg.Height}
+	number := len(e.pages) + 1
+	name := pageName(number)
+	if err := e.spool.add("OEBPS/images/"+name+"."+page.ext, zip.Store, imgBytes); err != nil {
+		return err
+	}
+	if err := e.spool.add("OEBPS/pages/"+name+".xhtml", zip.Deflate, e.pageXHTML(name, number, page)); err != nil {
+		return err
+	}
+
+	if e.pendingChapter != "" {
+		e.chapters = append(e.chapters, epubChapter{title: e.pendingChapter, start: len(e.pages)})
+		e.pendingChapter = ""
+	}
+	e.pages = append(e.pages, page)
+	logImageInfo(format, cfg.Width, cfg.Height, rawURL, fileName)
+	return nil
+}
+
+func (e *EPUBGenerator) startArchive(fileName string) error {
+	spool, err := createZipSpool(fileName)
+	if err != nil {
+		return err
+	}
+
+	// The mimetype entry has to come first, uncompressed and without extra
+	// fields, so its content sits at byte 38 where readers sniff for it.
+	if err := writeStoredEntry(spool.archive, "mimetype", []byte(epubMimetype)); err != nil {
+		spool.discard()
+		return err
+	}
+	if err := spool.add("META-INF/container.xml", zip.Deflate, []byte(epubContainer)); err != nil {
+		spool.discard()
+		return err
+	}
+	e.spool = spool
+	return nil
+}
+
+func (e *EPUBGenerator) Save(outputPath string) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.spool == nil {
+		return errors.New("EPUB has no pages")
+	}
+
+	title := e.metadata.Title()
+	if title == "" {
+		title = epubTitle(outputPath)
+	}
+
+	if err := e.spool.add("OEBPS/nav.xhtml", zip.Deflate, e.navXHTML(title)); err != nil {
+		return err
+	}
+	if err := e.spool.add("OEBPS/content.opf", zip.Deflate, e.packageOPF(title)); err != nil {
+		return err
+	}
+
+	if err := e.spool.commit(outputPath); err != nil {
+		return err
+	}
+	e.spool = nil
+	return nil
+}
+
+func (e *EPUBGenerator) Reset() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.spool.discard()
+	e.spool = nil
+	e.pages = nil
+	e.chapters = nil
+	e.pendingChapter = ""
+	e.metadata = Metadata{}
+}
+
+func (e *EPUBGenerator) pageXHTML(name string, number int, page epubPage) []byte {
+	return fmt.Appendf(nil, `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
+<head>
+<title>Page %d</title>
+<meta name="viewport" content="width=%d, height=%d"/>
+<style>html, body { margin: 0; padding: 0; } img { display: block; width: %dpx; height: %dpx; }</style>
+</head>
+<body>
+<img src="../images/%s.%s" alt="Page %d"/>
+</body>
+</html>
+`, number, page.width, page.height, page.width, page.height, name, page.ext, number)
+}
+
+func (e *EPUBGenerator) navXHTML(title string) []byte {
+	var toc, pageList strings.Builder
+	for _, ch := range e.chapters {
+		fmt.Fprintf(&toc, "<li><a href=\"pages/%s.xhtml\">%s</a></li>\n", pageName(ch.start+1), html.EscapeString(ch.title))
+	}
+	if len(e.chapters) == 0 {
+		fmt.Fprintf(&toc, "<li><a href=\"pages/%s.xhtml\">%s</a></li>\n", pageName(1), html.EscapeString(title))
+	}
+	for i := range e.pages {
+		fmt.Fprintf(&pageList, "<li><a href=\"pages/%s.xhtml\">%d</a></li>\n", pageName(i+1), i+1)
+	}
+
+	return fmt.Appendf(nil, `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
+<head>
+<title>%[1]s</title>
+</head>
+<body>
+<nav epub:type="toc" id="toc">
+<ol>
+%[2]s</ol>
+</nav>
+<nav epub:type="page-list" hidden="">
+<ol>
+%[3]s</ol>
+</nav>
+</body>
+</html>
+`, html.EscapeString(title), toc.String(), pageList.String())
+}
+
+func (e *EPUBGenerator) packageOPF(title string) []byte {
+	var manifest, spine strings.Builder
+	for i, page := range e.pages {
+		name := pageName(i + 1)
+		properties := ""
+		if i == 0 {
+			properties = ` properties="cover-image"`
+		}
+		fmt.Fprintf(&manifest, "<item id=\"img-%s\" href=\"images/%s.%s\" media-type=\"%s\"%s/>\n",
+			name, name, page.ext, epubMediaTypes[page.ext], properties)
+		fmt.Fprintf(&manifest, "<item id=\"page-%s\" href=\"pages/%s.xhtml\" media-type=\"application/xhtml+xml\"/>\n",
+			name, name)
+		fmt.Fprintf(&spine, "<itemref idref=\"page-%s\"/>\n", name)
+	}
+
+	return fmt.Appendf(nil, `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id" xml:lang="%[1]s">
+<metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+<dc:identifier id="book-id">urn:uuid:%[2]s</dc:identifier>
+<dc:title>%[3]s</dc:title>
+<dc:language>%[1]s</dc:language>
+%[4]s<meta property="dcterms:modified">%[5]s</meta>
+<meta property="rendition:layout">pre-paginated</meta>
+<meta property="rendition:orientation">auto</meta>
+<meta property="rendition:spread">none</meta>
+</metadata>
+<manifest>
+<item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+%[6]s</manifest>
+<spine page-progression-direction="%[7]s">
+%[8]s</spine>
+</package>
+`, html.EscapeString(e.metadata.LanguageTag()), newUUID(), html.EscapeString(title), e.metadataOPF(), time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+		manifest.String(), e.direction, spine.String())
+}
+
+// metadataOPF renders the optional package metadata, with the series as an
+// EPUB3 collection so readers group the volumes together.
+func (e *EPUBGenerator) metadataOPF() string {
+	var b strings.Builder
+	meta := e.metadata
+	creators := meta.Creators()
+	if len(creators) == 0 {
+		creators = []string{creatorName}
+	}
+	for _, c := range creators {
+		fmt.Fprintf(&b, "<dc:creator>%s</dc:creator>\n", html.EscapeString(c))
+	}
+	if meta.Synopsis != "" {
+		fmt.Fprintf(&b, "<dc:description>%s</dc:description>\n", html.EscapeString(meta.Synopsis))
+	}
+	if meta.SourceURL != "" {
+		fmt.Fprintf(&b, "<dc:source>%s</dc:source>\n", html.EscapeString(meta.SourceURL))
+	}
+	date := meta.Date
+	if !meta.Released.IsZero() {
+		date = meta.Released
+	}
+	if !date.IsZero() {
+		fmt.Fprintf(&b, "<dc:date>%s</dc:date>\n", date.UTC().Format("2006-01-02T15:04:05Z"))
+	}
+	if meta.Scanlator != "" {
+		fmt.Fprintf(&b, "<dc:contributor>%s</dc:contributor>\n", html.EscapeString(meta.Scanlator))
+	}
+	for _, k := range meta.Keywords() {
+		fmt.Fprintf(&b, "<dc:subject>%s</dc:subject>\n", html.EscapeString(k))
+	}
+	if meta.Series != "" {
+		fmt.Fprintf(&b, "<meta property=\"belongs-to-collection\" id=\"series\">%s</meta>\n", html.EscapeString(meta.Series))
+		b.WriteString("<meta refines=\"#series\" property=\"collection-type\">series</meta>\n")
+		if _, err := strconv.ParseFloat(meta.Chapter, 64); err == nil {
+			fmt.Fprintf(&b, "<meta refines=\"#series\" property=\"group-position\">%s</meta>\n", meta.Chapter)
+		}
+	}
+	return b.String()
+}
+
+var epubMediaTypes = map[string]string{
+	"jpg":  "image/jpeg",
+	"png":  "image/png",
+	"gif":  "image/gif",
+	"webp": "image/webp",
+}
+
+const epubMimetype = "application/epub+zip"
+
+const epubContainer = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+<rootfiles>
+<rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+</rootfiles>
+</container>
+`
+
+// epubTitle names the book after the comic folder and the chapter file,
+// e.g. "one-piece 01-10".
+func epubTitle(outputPath string) string {
+	name := strings.TrimSuffix(filepath.Base(outputPath), filepath.Ext(outputPath))
+	if dir := filepath.Base(filepath.Dir(outputPath)); dir != "." && dir != string(filepath.Separator) {
+		return dir + " " + name
+	}
+	return name
+}
+
+func writeZipEntry(archive *zip.Writer, name string, method uint16, data []byte) error {
+	w, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: method, Modified: time.Now()})
+	if err != nil {
+		return fmt.Errorf("failed to create archive entry %s: %w", name, err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to write archive entry %s: %w", name, err)
+	}
+	return nil
+}
+
+// writeStoredEntry writes an uncompressed entry with its checksum and sizes
+// in the local header, leaving out the timestamp extra field and the data
+// descriptor CreateHeader would add.
+func writeStoredEntry(archive *zip.Writer, name string, data []byte) error {
+	w, err := archive.CreateRaw(&zip.FileHeader{
+		Name:               name,
+		Method:             zip.Store,
+		CRC32:              crc32.ChecksumIEEE(data),
+		CompressedSize64:   uint64(len(data)),
+		UncompressedSize64: uint64(len(data)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create archive entry %s: %w", name, err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to write archive entry %s: %w", name, err)
+	}
+	return nil
+}
+
+func newUUID() string {
+	var b [16]byte
+	if _, err := io.ReadFull(rand.Reader, b[:]); err != nil {
+		return fmt.Sprintf("%032x", time.Now().UnixNano())
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,70 @@
+package exports
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestEPUBMimetypeOffset(t *testing.T) {
+	outputPath := filepath.Join(t.TempDir(), "01.epub")
+	e := NewEPUBGenerator(DirectionLTR)
+	if err := e.AddImage(testPNG(t, 4, 6), outputPath, "https://example.com/1.png"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Save(outputPath); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data[30:38]); got != "mimetype" {
+		t.Fatalf("first entry is %q, want mimetype", got)
+	}
+	if got := string(data[38 : 38+len(epubMimetype)]); got != epubMimetype {
+		t.Fatalf("bytes at offset 38 are %q, want %q", got, epubMimetype)
+	}
+	// The local header flags, method and extra field length.
+	if flags := uint16(data[6]) | uint16(data[7])<<8; flags&0x8 != 0 {
+		t.Errorf("mimetype entry sets the data descriptor flag")
+	}
+	if method := uint16(data[8]) | uint16(data[9])<<8; method != 0 {
+		t.Errorf("mimetype entry uses method %d, want stored", method)
+	}
+	if extra := uint16(data[28]) | uint16(data[29])<<8; extra != 0 {
+		t.Errorf("mimetype entry has %d bytes of extra fields", extra)
+	}
+}
+
+func TestEPUBLanguage(t *testing.T) {
+	tests := []struct {
+		language string
+		want     string
+	}{
+		{"", "<dc:language>en</dc:language>"},
+		{"id", "<dc:language>id</dc:language>"},
+	}
+	for _, tt := range tests {
+		e := NewEPUBGenerator(DirectionRTL)
+		e.SetMetadata(Metadata{Series: "Test", Language: tt.language})
+		e.pages = []epubPage{{ext: "png", width: 4, height: 6}}
+		if opf := string(e.packageOPF("Test")); !strings.Contains(opf, tt.want) {
+			t.Errorf("language %q: package document lacks %s", tt.language, tt.want)
+		}
+	}
+}
@@ -1,15 +1,83 @@
 package exports
 
-type DocumentExporter struct {
-	PDF interface {
-		AddImageToPDF(imgBytes []byte, imgLink, rawURL string) error
-		SavePDF(outputPath string) error
-		Reset()
+import (
+	"slices"
+	"strings"
+)
+
+// Exporter turns a sequence of page images into one output document. An
+// exporter is reused between documents, Reset clears what Save wrote.
+// AddChapter marks the next added image as the first page of a chapter, for
+// formats that can navigate by chapter. SetMetadata describes the document
+// and applies until Reset.
+type Exporter interface {
+	SetMetadata(meta Metadata)
+	AddChapter(title string)
+	AddImage(imgBytes []byte, fileName, rawURL string) error
+	Save(outputPath string) error
+	Reset()
+}
+
+type Options struct {
+	Direction string
+	// SliceHeight re-slices long strips into pages this many pixels tall.
+	// Zero keeps the pages as downloaded.
+	SliceHeight int
+	Layout      PageLayout
+	// Lossless keeps re-sliced pages made from PNG or other lossless sources
+	// in PNG instead of encoding them to JPEG.
+	Lossless bool
+	// JPEGQuality is used for pages the exporters have to re-encode as JPEG.
+	// Zero picks defaultJPEGQuality.
+	JPEGQuality int
+}
+
+// Format describes an output format that can be selected from the CLI.
+type Format struct {
+	Name string
+	// Extension is appended to the output name. An empty extension means the
+	// output is a directory.
+	Extension string
+	// Original formats receive the image bytes exactly as downloaded unless
+	// the CLI picks an image mode, the others get JPEGs by default.
+	Original bool
+	New      func(opts Options) Exporter
+}
+
+var formats = map[string]Format{}
+
+func RegisterFormat(format Format) {
+	formats[format.Name] = format
+}
+
+func LookupFormat(name string) (Format, bool) {
+	format, ok := formats[strings.ToLower(strings.TrimSpace(name))]
+	return format, ok
+}
+
+func FormatNames() []string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// NewExporter creates an exporter for the format, put behind the strip
+// re-slicer when opts asks for uniform page heights.
+func (f Format) NewExporter(opts Options) Exporter {
+	exporter := f.New(opts)
+	if opts.SliceHeight > 0 {
+		return newReslicer(exporter, opts)
 	}
+	return exporter
 }
 
-func NewDocumentExporter() *DocumentExporter {
-	return &DocumentExporter{
-		PDF: NewPDFGenerator(),
+// OutputName appends the format extension to name, if the format has one.
+func (f Format) OutputName(name string) string {
+	if f.Extension == "" {
+		return name
 	}
+	return name + "." + f.Extension
 }
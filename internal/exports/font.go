@@ -0,0 +1,303 @@
+package exports
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+const pdfFontName = "GoRegular"
+
+// pdfFont is the TrueType font generated pages are set in. It is embedded as
+// a CID font with Identity-H encoding, so text is written as glyph ids and
+// every script the font has glyphs for can be shown, with a ToUnicode map to
+// keep the text searchable. Only the glyphs a document uses are embedded, see
+// pdfFontSubset. Widths are kept per glyph in thousandths of an em.
+type pdfFont struct {
+	data      []byte
+	glyphs    map[rune]uint16
+	widths    []int
+	ascent    int
+	descent   int
+	capHeight int
+	bbox      [4]int
+}
+
+var loadPDFFont = sync.OnceValues(func() (*pdfFont, error) {
+	parsed, err := sfnt.Parse(goregular.TTF)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse font: %w", err)
+	}
+
+	var buf sfnt.Buffer
+	unitsPerEm := int(parsed.UnitsPerEm())
+	ppem := fixed.I(unitsPerEm)
+	toEm := func(v fixed.Int26_6) int { return int(v) * 1000 / (unitsPerEm * 64) }
+
+	f := &pdfFont{
+		data:   goregular.TTF,
+		glyphs: make(map[rune]uint16),
+		widths: make([]int, parsed.NumGlyphs()),
+	}
+	for glyph := range f.widths {
+		advance, err := parsed.GlyphAdvance(&buf, sfnt.GlyphIndex(glyph), ppem, font.HintingNone)
+		if err != nil {
+			continue
+		}
+		f.widths[glyph] = toEm(advance)
+	}
+	for r := rune(0x20); r <= 0xffff; r++ {
+		if !unicode.IsPrint(r) {
+			continue
+		}
+		if glyph, err := parsed.GlyphIndex(&buf, r); err == nil && glyph != 0 {
+			f.glyphs[r] = uint16(glyph)
+		}
+	}
+
+	metrics, err := parsed.Metrics(&buf, ppem, font.HintingNone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read font metrics: %w", err)
+	}
+	bounds, err := parsed.Bounds(&buf, ppem, font.HintingNone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read font bounds: %w", err)
+	}
+	f.ascent, f.descent, f.capHeight = toEm(metrics.Ascent), toEm(metrics.Descent), toEm(metrics.CapHeight)
+	// sfnt measures downwards, PDF upwards.
+	f.bbox = [4]int{toEm(bounds.Min.X), -toEm(bounds.Max.Y), toEm(bounds.Max.X), -toEm(bounds.Min.Y)}
+	return f, nil
+})
+
+// covers reports whether the font has a glyph for every character of s.
+func (f *pdfFont) covers(s string) bool {
+	for _, r := range s {
+		if _, ok := f.glyphs[r]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// drawable cuts s at the first character the font has no glyph for, such as
+// the Japanese name in "Chapter 5: 第5話", and drops the separator left
+// dangling, so the line still reads "Chapter 5".
+func (f *pdfFont) drawable(s string) string {
+	for i, r := range s {
+		if _, ok := f.glyphs[r]; !ok {
+			return strings.TrimRightFunc(s[:i], func(r rune) bool {
+				return unicode.IsSpace(r) || unicode.IsPunct(r)
+			})
+		}
+	}
+	return s
+}
+
+// encode turns s into two byte glyph ids, characters the font lacks become
+// "?".
+func (f *pdfFont) encode(s string) []uint16 {
+	glyphs := make([]uint16, 0, len(s))
+	for _, r := range s {
+		glyph, ok := f.glyphs[r]
+		if !ok {
+			glyph = f.glyphs['?']
+		}
+		glyphs = append(glyphs, glyph)
+	}
+	return glyphs
+}
+
+// width is the advance of s at size points.
+func (f *pdfFont) width(s string, size float64) float64 {
+	total := 0
+	for _, glyph := range f.encode(s) {
+		total += f.widths[glyph]
+	}
+	return float64(total) * size / 1000
+}
+
+// fit shortens s with an ellipsis until it is at most maxWidth wide.
+func (f *pdfFont) fit(s string, size, maxWidth float64) string {
+	if f.width(s, size) <= maxWidth {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && f.width(string(runes)+"...", size) > maxWidth {
+		runes = runes[:len(runes)-1]
+	}
+	return strings.TrimSpace(string(runes)) + "..."
+}
+
+// show renders s as a hex string operand for Tj.
+func (f *pdfFont) show(s string) string {
+	var b strings.Builder
+	b.WriteByte('<')
+	for _, glyph := range f.encode(s) {
+		fmt.Fprintf(&b, "%04X", glyph)
+	}
+	b.WriteByte('>')
+	return b.String()
+}
+
+// pdfFontSubset is the font as one document uses it. It records every glyph
+// shown, so only those glyphs are embedded.
+type pdfFontSubset struct {
+	*pdfFont
+	used map[uint16]bool
+}
+
+func (f *pdfFont) subset() *pdfFontSubset {
+	return &pdfFontSubset{pdfFont: f, used: make(map[uint16]bool)}
+}
+
+// show renders s as a hex string operand for Tj and marks its glyphs used.
+func (s *pdfFontSubset) show(text string) string {
+	for _, glyph := range s.encode(text) {
+		s.used[glyph] = true
+	}
+	return s.pdfFont.show(text)
+}
+
+// usedGlyphs lists the glyphs used so far in order.
+func (s *pdfFontSubset) usedGlyphs() []uint16 {
+	return slices.Sorted(maps.Keys(s.used))
+}
+
+// name is the font name with the six letter tag PDF asks for in front of
+// subsets, taken from the glyphs used so different subsets get different
+// names.
+func (s *pdfFontSubset) name() string {
+	hash := fnv.New32a()
+	for _, glyph := range s.usedGlyphs() {
+		binary.Write(hash, binary.BigEndian, glyph)
+	}
+	sum := hash.Sum32()
+	tag := make([]byte, 6)
+	for i := range tag {
+		tag[i] = 'A' + byte(sum%26)
+		sum /= 26
+	}
+	return string(tag) + "+" + pdfFontName
+}
+
+// toUnicode is the CMap that maps the glyphs used back to text for search
+// and copying. Where several characters share a glyph the lowest one is used.
+func (s *pdfFontSubset) toUnicode() []byte {
+	chars := make(map[uint16]rune, len(s.used))
+	for r, glyph := range s.glyphs {
+		if !s.used[glyph] {
+			continue
+		}
+		if prev, ok := chars[glyph]; !ok || r < prev {
+			chars[glyph] = r
+		}
+	}
+	glyphs := slices.Sorted(maps.Keys(chars))
+
+	var b strings.Builder
+	b.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n" +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n" +
+		"/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n" +
+		"1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	// A bfchar block holds at most 100 entries.
+	for chunk := range slices.Chunk(glyphs, 100) {
+		fmt.Fprintf(&b, "%d beginbfchar\n", len(chunk))
+		for _, glyph := range chunk {
+			fmt.Fprintf(&b, "<%04X> <%04X>\n", glyph, chars[glyph])
+		}
+		b.WriteString("endbfchar\n")
+	}
+	b.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+	return []byte(b.String())
+}
+
+// widthArray is the /W array of the glyphs used, consecutive glyphs share one
+// run.
+func (s *pdfFontSubset) widthArray() string {
+	var b strings.Builder
+	glyphs := s.usedGlyphs()
+	for i, glyph := range glyphs {
+		switch {
+		case i == 0:
+			fmt.Fprintf(&b, "%d [", glyph)
+		case glyph == glyphs[i-1]+1:
+			b.WriteByte(' ')
+		default:
+			fmt.Fprintf(&b, "] %d [", glyph)
+		}
+		fmt.Fprint(&b, s.widths[glyph])
+	}
+	if len(glyphs) > 0 {
+		b.WriteByte(']')
+	}
+	return b.String()
+}
+
+// writeFont embeds the glyphs of the subset used so far as the font
+// dictionary id.
+func (w *pdfWriter) writeFont(s *pdfFontSubset, id int) error {
+	data, err := subsetTrueType(s.data, s.used)
+	if err != nil {
+		return fmt.Errorf("failed to subset font: %w", err)
+	}
+	compressed, err := deflate(data)
+	if err != nil {
+		return fmt.Errorf("failed to compress font: %w", err)
+	}
+	fileID := w.newObject()
+	if err := w.writeStream(fileID, fmt.Sprintf("/Filter /FlateDecode /Length1 %d", len(data)), compressed); err != nil {
+		return err
+	}
+
+	name := s.name()
+	descriptorID := w.newObject()
+	descriptor := fmt.Sprintf("<< /Type /FontDescriptor /FontName /%s /Flags 32 /FontBBox [%d %d %d %d] /ItalicAngle 0 /Ascent %d /Descent %d /CapHeight %d /StemV 80 /FontFile2 %d 0 R >>",
+		name, s.bbox[0], s.bbox[1], s.bbox[2], s.bbox[3], s.ascent, -s.descent, s.capHeight, fileID)
+	if err := w.writeObject(descriptorID, descriptor); err != nil {
+		return err
+	}
+
+	cidFontID := w.newObject()
+	cidFont := fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /FontDescriptor %d 0 R /W [%s] /CIDToGIDMap /Identity >>",
+		name, descriptorID, s.widthArray())
+	if err := w.writeObject(cidFontID, cidFont); err != nil {
+		return err
+	}
+
+	cmap, err := deflate(s.toUnicode())
+	if err != nil {
+		return fmt.Errorf("failed to compress font: %w", err)
+	}
+	cmapID := w.newObject()
+	if err := w.writeStream(cmapID, "/Filter /FlateDecode", cmap); err != nil {
+		return err
+	}
+
+	dict := fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>",
+		name, cidFontID, cmapID)
+	return w.writeObject(id, dict)
+}
+
+func deflate(data []byte) ([]byte, error) {
+	compressed := new(bytes.Buffer)
+	zw := zlib.NewWriter(compressed)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return compressed.Bytes(), nil
+}
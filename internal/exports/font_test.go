@@ -0,0 +1,99 @@
+package exports
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestPDFFontDrawable(t *testing.T) {
+	f, err := loadPDFFont()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Chapter 5: The Beginning", "Chapter 5: The Beginning"},
+		{"Chapter 5: Café Crème", "Chapter 5: Café Crème"},
+		{"Chapter 5: Начало", "Chapter 5: Начало"},
+		{"Chapter 5: Αρχή", "Chapter 5: Αρχή"},
+		{"Chapter 5: 第5話", "Chapter 5"},
+		{"Chapter 5 - 시작", "Chapter 5"},
+		{"第5話", ""},
+	}
+	for _, tt := range tests {
+		if got := f.drawable(tt.text); got != tt.want {
+			t.Errorf("drawable(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+		if covered := f.covers(tt.text); covered != (tt.want == tt.text) {
+			t.Errorf("covers(%q) = %v", tt.text, covered)
+		}
+	}
+}
+
+func TestContentsTitle(t *testing.T) {
+	f, err := loadPDFFont()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		meta Metadata
+		want string
+	}{
+		{"latin", Metadata{Series: "One Piece", SourceURL: "https://example.com/manga/one-piece/"}, "One Piece"},
+		{"cyrillic", Metadata{Series: "Ван Пис", SourceURL: "https://example.com/manga/one-piece/"}, "Ван Пис"},
+		{"japanese falls back to slug", Metadata{Series: "ワンピース", SourceURL: "https://example.com/manga/one-piece/"}, "one-piece"},
+		{"no source URL", Metadata{Series: "Solo Leveling 나 혼자만 레벨업"}, "Solo Leveling"},
+	}
+	for _, tt := range tests {
+		if got := contentsTitle(f, tt.meta); got != tt.want {
+			t.Errorf("%s: contentsTitle = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPDFFontSubset(t *testing.T) {
+	f, err := loadPDFFont()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := f.subset()
+	s.show("AéЖΩ")
+	s.show("AB")
+
+	cmap := string(s.toUnicode())
+	for _, r := range "ABéЖΩ" {
+		if want := fmt.Sprintf("%s <%04X>", f.show(string(r)), r); !strings.Contains(cmap, want) {
+			t.Errorf("ToUnicode map lacks %s for %q", want, r)
+		}
+	}
+	if unused := f.show("Z") + " <"; strings.Contains(cmap, unused) {
+		t.Errorf("ToUnicode map has unused glyph %s", unused)
+	}
+
+	// A and B are neighbours in the font, so they share a run.
+	a, b := f.glyphs['A'], f.glyphs['B']
+	if b != a+1 {
+		t.Fatalf("glyphs of A and B are %d and %d", a, b)
+	}
+	if want := fmt.Sprintf("%d [%d %d]", a, f.widths[a], f.widths[b]); !strings.HasPrefix(s.widthArray(), want) {
+		t.Errorf("widthArray() = %q, want it to start with %q", s.widthArray(), want)
+	}
+	if runs := strings.Count(s.widthArray(), "["); runs != 4 {
+		t.Errorf("widthArray() = %q, want 4 runs", s.widthArray())
+	}
+
+	name := s.name()
+	if !regexp.MustCompile(`^[A-Z]{6}\+` + pdfFontName + `$`).MatchString(name) {
+		t.Errorf("name() = %q, want a subset tag", name)
+	}
+	other := f.subset()
+	other.show("Z")
+	if other.name() == name {
+		t.Errorf("subsets of different glyphs share the name %q", name)
+	}
+}
@@ -0,0 +1,131 @@
+package exports
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	LayoutNative   = "native"
+	LayoutFit      = "fit"
+	LayoutFitWidth = "fit-width"
+
+	ptsPerMillimetre = ptsPerInch / 25.4
+)
+
+// pageSizes holds the named paper sizes in points.
+var pageSizes = map[string][2]float64{
+	"a4":     {595.28, 841.89},
+	"a5":     {419.53, 595.28},
+	"b5":     {498.90, 708.66},
+	"letter": {612, 792},
+}
+
+// PageLayout decides how images are placed on PDF pages. Native pages are
+// sized after the image, fit pages have a fixed paper size with the image
+// scaled into the margins, and fit-width pages keep the paper width but grow
+// as tall as the scaled image needs.
+type PageLayout struct {
+	Mode       string
+	Width      float64
+	Height     float64
+	Margin     float64
+	Background color.RGBA
+}
+
+// pageBox is where one image ends up: the page size and the rectangle the
+// image is drawn into, both in points.
+type pageBox struct {
+	width, height float64
+	x, y, w, h    float64
+	background    *color.RGBA
+}
+
+// ParsePageLayout builds a layout from the CLI values. size is a paper name
+// (a4, a5, b5, letter) or WIDTHxHEIGHT in millimetres, margin is in
+// millimetres and background is a hex colour like "ffffff".
+func ParsePageLayout(mode, size string, margin float64, background string) (PageLayout, error) {
+	layout := PageLayout{Mode: strings.ToLower(mode), Margin: margin * ptsPerMillimetre}
+	switch layout.Mode {
+	case LayoutNative, LayoutFit, LayoutFitWidth:
+	default:
+		return layout, fmt.Errorf("unknown page layout %q, use native, fit or fit-width", mode)
+	}
+
+	width, height, err := parsePageSize(size)
+	if err != nil {
+		return layout, err
+	}
+	layout.Width, layout.Height = width, height
+
+	// Written so a NaN margin fails too.
+	if !(margin >= 0) || 2*layout.Margin >= min(width, height) {
+		return layout, fmt.Errorf("page margin %.1fmm does not fit the page", margin)
+	}
+
+	if layout.Background, err = parseHexColor(background); err != nil {
+		return layout, err
+	}
+	return layout, nil
+}
+
+func parsePageSize(size string) (float64, float64, error) {
+	size = strings.ToLower(strings.TrimSpace(size))
+	if dims, ok := pageSizes[size]; ok {
+		return dims[0], dims[1], nil
+	}
+
+	w, h, ok := strings.Cut(size, "x")
+	if ok {
+		width, errW := strconv.ParseFloat(w, 64)
+		height, errH := strconv.ParseFloat(h, 64)
+		if errW == nil && errH == nil && width > 0 && height > 0 && !math.IsInf(width+height, 0) {
+			return width * ptsPerMillimetre, height * ptsPerMillimetre, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("unknown page size %q, use a4, a5, b5, letter or WIDTHxHEIGHT in mm", size)
+}
+
+func parseHexColor(value string) (color.RGBA, error) {
+	hex := strings.TrimPrefix(strings.TrimSpace(value), "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	rgb, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || len(hex) != 6 {
+		return color.RGBA{}, fmt.Errorf("invalid background colour %q, use a hex value like ffffff", value)
+	}
+	return color.RGBA{R: uint8(rgb >> 16), G: uint8(rgb >> 8), B: uint8(rgb), A: 255}, nil
+}
+
+// place works out the page for an image of width x height pixels.
+func (l PageLayout) place(width, height int) pageBox {
+	imgW, imgH := float64(width), float64(height)
+
+	switch l.Mode {
+	case LayoutFit:
+		areaW, areaH := l.Width-2*l.Margin, l.Height-2*l.Margin
+		scale := min(areaW/imgW, areaH/imgH)
+		w, h := imgW*scale, imgH*scale
+		return pageBox{
+			width: l.Width, height: l.Height,
+			x: (l.Width - w) / 2, y: (l.Height - h) / 2, w: w, h: h,
+			background: &l.Background,
+		}
+	case LayoutFitWidth:
+		w := l.Width - 2*l.Margin
+		h := imgH * w / imgW
+		return pageBox{
+			width: l.Width, height: h + 2*l.Margin,
+			x: l.Margin, y: l.Margin, w: w, h: h,
+			background: &l.Background,
+		}
+	default:
+		w := imgW*ptsPerInch/dpi - 1
+		h := imgH*ptsPerInch/dpi - 1
+		return pageBox{width: w, height: h, w: w, h: h}
+	}
+}
@@ -0,0 +1,116 @@
+package exports
+
+import (
+	"image/color"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestParsePageLayout(t *testing.T) {
+	const mm = ptsPerMillimetre
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	tests := []struct {
+		mode, size string
+		margin     float64
+		background string
+		want       PageLayout
+		wantErr    string
+	}{
+		{mode: "fit", size: "a4", background: "ffffff", want: PageLayout{Mode: LayoutFit, Width: 595.28, Height: 841.89, Background: white}},
+		{mode: "FIT-WIDTH", size: " Letter ", margin: 10, background: "#fff", want: PageLayout{Mode: LayoutFitWidth, Width: 612, Height: 792, Margin: 10 * mm, Background: white}},
+		{mode: "native", size: "a5", background: "1a2B3c", want: PageLayout{Mode: LayoutNative, Width: 419.53, Height: 595.28, Background: color.RGBA{R: 0x1a, G: 0x2b, B: 0x3c, A: 255}}},
+		{mode: "fit", size: "100x150", margin: 5, background: "000", want: PageLayout{Mode: LayoutFit, Width: 100 * mm, Height: 150 * mm, Margin: 5 * mm, Background: color.RGBA{A: 255}}},
+		{mode: "fit", size: "120.5X200", background: "fff", want: PageLayout{Mode: LayoutFit, Width: 120.5 * mm, Height: 200 * mm, Background: white}},
+
+		{mode: "stretch", size: "a4", background: "fff", wantErr: "unknown page layout"},
+		{mode: "fit", size: "a3", background: "fff", wantErr: "unknown page size"},
+		{mode: "fit", size: "100x", background: "fff", wantErr: "unknown page size"},
+		{mode: "fit", size: "0x100", background: "fff", wantErr: "unknown page size"},
+		{mode: "fit", size: "-10x100", background: "fff", wantErr: "unknown page size"},
+		{mode: "fit", size: "infx100", background: "fff", wantErr: "unknown page size"},
+		{mode: "fit", size: "a4", margin: -1, background: "fff", wantErr: "does not fit the page"},
+		{mode: "fit", size: "a4", margin: math.NaN(), background: "fff", wantErr: "does not fit the page"},
+		// Half the width of a 100mm page leaves nothing to draw on.
+		{mode: "fit", size: "100x200", margin: 50, background: "fff", wantErr: "does not fit the page"},
+		{mode: "fit", size: "100x200", margin: 49, background: "fff", want: PageLayout{Mode: LayoutFit, Width: 100 * mm, Height: 200 * mm, Margin: 49 * mm, Background: white}},
+		{mode: "fit", size: "a4", background: "white", wantErr: "invalid background colour"},
+		{mode: "fit", size: "a4", background: "fffffff", wantErr: "invalid background colour"},
+		{mode: "fit", size: "a4", background: "", wantErr: "invalid background colour"},
+	}
+	for _, tt := range tests {
+		got, err := ParsePageLayout(tt.mode, tt.size, tt.margin, tt.background)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParsePageLayout(%q, %q, %v, %q) error = %v, want %q", tt.mode, tt.size, tt.margin, tt.background, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParsePageLayout(%q, %q, %v, %q) error = %v", tt.mode, tt.size, tt.margin, tt.background, err)
+			continue
+		}
+		if !closeTo(got.Width, tt.want.Width) || !closeTo(got.Height, tt.want.Height) || !closeTo(got.Margin, tt.want.Margin) ||
+			got.Mode != tt.want.Mode || got.Background != tt.want.Background {
+			t.Errorf("ParsePageLayout(%q, %q, %v, %q) = %+v, want %+v", tt.mode, tt.size, tt.margin, tt.background, got, tt.want)
+		}
+	}
+}
+
+func closeTo(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestPageLayoutPlace(t *testing.T) {
+	// A 200x400pt page with a 10pt margin leaves 180x380 to draw on.
+	layout := func(mode string) PageLayout {
+		return PageLayout{Mode: mode, Width: 200, Height: 400, Margin: 10}
+	}
+	tests := []struct {
+		name          string
+		layout        PageLayout
+		width, height int
+		want          pageBox
+	}{
+		{
+			name: "fit tall image fills the height", layout: layout(LayoutFit), width: 100, height: 400,
+			want: pageBox{width: 200, height: 400, x: 52.5, y: 10, w: 95, h: 380},
+		},
+		{
+			name: "fit wide image fills the width", layout: layout(LayoutFit), width: 360, height: 180,
+			want: pageBox{width: 200, height: 400, x: 10, y: 155, w: 180, h: 90},
+		},
+		{
+			name: "fit small image is scaled up", layout: layout(LayoutFit), width: 18, height: 38,
+			want: pageBox{width: 200, height: 400, x: 10, y: 10, w: 180, h: 380},
+		},
+		{
+			name: "fit-width keeps the width and grows the page", layout: layout(LayoutFitWidth), width: 90, height: 1000,
+			want: pageBox{width: 200, height: 2020, x: 10, y: 10, w: 180, h: 2000},
+		},
+		{
+			name: "fit-width short image gives a short page", layout: layout(LayoutFitWidth), width: 360, height: 90,
+			want: pageBox{width: 200, height: 65, x: 10, y: 10, w: 180, h: 45},
+		},
+		{
+			name: "native page follows the image", layout: layout(LayoutNative), width: 256, height: 512,
+			want: pageBox{width: 143, height: 287, w: 143, h: 287},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.layout.place(tt.width, tt.height)
+			if !closeTo(got.width, tt.want.width) || !closeTo(got.height, tt.want.height) ||
+				!closeTo(got.x, tt.want.x) || !closeTo(got.y, tt.want.y) ||
+				!closeTo(got.w, tt.want.w) || !closeTo(got.h, tt.want.h) {
+				t.Errorf("place(%d, %d) = page %gx%g, image %gx%g at %g,%g, want page %gx%g, image %gx%g at %g,%g",
+					tt.width, tt.height, got.width, got.height, got.w, got.h, got.x, got.y,
+					tt.want.width, tt.want.height, tt.want.w, tt.want.h, tt.want.x, tt.want.y)
+			}
+			// Native pages have no background, the others paint theirs.
+			if (got.background == nil) != (tt.layout.Mode == LayoutNative) {
+				t.Errorf("background set is %v for %s", got.background != nil, tt.layout.Mode)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package exports
+
+import (
+	"fmt"
+	"html"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+const (
+	creatorName     = "comdown"
+	defaultLanguage = "en"
+)
+
+// Metadata describes the document being exported so library tools can index
+// it. Chapter holds a single chapter number or a merged range like "01-10".
+// Volume is only known on sites that show it. ChapterTitle, Released and
+// Scanlator describe a single chapter and come from the chapter list when the
+// site config reads them. Cover holds the series cover image, if the site has
+// one, and the fields below it come from the series page. AltTitles end up
+// in the keywords. Language is a BCP 47 tag, empty when the site config does
+// not say.
+type Metadata struct {
+	Series       string
+	Chapter      string
+	Volume       string
+	ChapterTitle string
+	Released     time.Time
+	Scanlator    string
+	SourceURL    string
+	Date         time.Time
+	Cover        []byte
+	AltTitles    []string
+	Authors      []string
+	Artists      []string
+	Genres       []string
+	Status       string
+	Synopsis     string
+	Language     string
+}
+
+// LanguageTag returns Language, or "en" when it is not known.
+func (m Metadata) LanguageTag() string {
+	if m.Language == "" {
+		return defaultLanguage
+	}
+	return m.Language
+}
+
+func (m Metadata) IsZero() bool {
+	return m.Series == "" && m.Chapter == "" && m.SourceURL == ""
+}
+
+func (m Metadata) chapterLabel() string {
+	if m.Chapter == "" {
+		return ""
+	}
+	if strings.Contains(m.Chapter, "-") {
+		return "Chapters " + m.Chapter
+	}
+	return "Chapter " + m.Chapter
+}
+
+func (m Metadata) Title() string {
+	switch {
+	case m.Series == "":
+		return m.chapterLabel()
+	case m.Chapter == "":
+		return m.Series
+	default:
+		return m.Series + " - " + m.chapterLabel()
+	}
+}
+
+func (m Metadata) Subject() string {
+	if m.Series == "" || m.Chapter == "" {
+		return m.Title()
+	}
+	return fmt.Sprintf("%s of %s", m.chapterLabel(), m.Series)
+}
+
+// Keywords lists the series, its other titles, the chapter, the source and
+// the genres, so library tools find a book by any of its names.
+func (m Metadata) Keywords() []string {
+	var keywords []string
+	fields := slices.Concat([]string{m.Series}, m.AltTitles, []string{m.chapterLabel(), m.SourceURL}, m.Genres)
+	for _, k := range fields {
+		if k != "" {
+			keywords = append(keywords, k)
+		}
+	}
+	return keywords
+}
+
+// Creators lists the authors followed by the artists who are not also
+// authors.
+func (m Metadata) Creators() []string {
+	creators := slices.Clone(m.Authors)
+	for _, artist := range m.Artists {
+		if !slices.Contains(creators, artist) {
+			creators = append(creators, artist)
+		}
+	}
+	return creators
+}
+
+// Description is the synopsis when the site has one and the subject
+// otherwise.
+func (m Metadata) Description() string {
+	if m.Synopsis != "" {
+		return m.Synopsis
+	}
+	return m.Subject()
+}
+
+// xmpPacket renders the metadata as an XMP packet for the PDF catalog.
+func (m Metadata) xmpPacket() string {
+	date := m.Date.Format(time.RFC3339)
+	var subjects strings.Builder
+	for _, k := range m.Keywords() {
+		fmt.Fprintf(&subjects, "<rdf:li>%s</rdf:li>", html.EscapeString(k))
+	}
+	var creators strings.Builder
+	for _, c := range m.Creators() {
+		fmt.Fprintf(&creators, "<rdf:li>%s</rdf:li>", html.EscapeString(c))
+	}
+
+	return fmt.Sprintf(`<?xpacket begin="%s" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+<rdf:Description rdf:about=""
+ xmlns:dc="http://purl.org/dc/elements/1.1/"
+ xmlns:xmp="http://ns.adobe.com/xap/1.0/"
+ xmlns:pdf="http://ns.adobe.com/pdf/1.3/">
+<dc:format>application/pdf</dc:format>
+<dc:title><rdf:Alt><rdf:li xml:lang="x-default">%s</rdf:li></rdf:Alt></dc:title>
+<dc:creator><rdf:Seq>%s</rdf:Seq></dc:creator>
+<dc:description><rdf:Alt><rdf:li xml:lang="x-default">%s</rdf:li></rdf:Alt></dc:description>
+<dc:source>%s</dc:source>
+<dc:subject><rdf:Bag>%s</rdf:Bag></dc:subject>
+<pdf:Keywords>%s</pdf:Keywords>
+<pdf:Producer>%s</pdf:Producer>
+<xmp:CreatorTool>%s</xmp:CreatorTool>
+<xmp:CreateDate>%s</xmp:CreateDate>
+<xmp:ModifyDate>%s</xmp:ModifyDate>
+<xmp:MetadataDate>%s</xmp:MetadataDate>
+</rdf:Description>
+</rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>`, "\ufeff",
+		html.EscapeString(m.Title()), creators.String(), html.EscapeString(m.Description()), html.EscapeString(m.SourceURL),
+		subjects.String(), html.EscapeString(strings.Join(m.Keywords(), ", ")),
+		creatorName, creatorName, date, date, date)
+}
+
+// pdfInfo renders the document information dictionary.
+func (m Metadata) pdfInfo() string {
+	var b strings.Builder
+	b.WriteString("<<\n")
+	fmt.Fprintf(&b, "/Title %s\n", pdfTextString(m.Title()))
+	if creators := m.Creators(); len(creators) > 0 {
+		fmt.Fprintf(&b, "/Author %s\n", pdfTextString(strings.Join(creators, ", ")))
+	}
+	fmt.Fprintf(&b, "/Subject %s\n", pdfTextString(m.Subject()))
+	fmt.Fprintf(&b, "/Keywords %s\n", pdfTextString(strings.Join(m.Keywords(), ", ")))
+	fmt.Fprintf(&b, "/Creator %s\n", pdfTextString(creatorName))
+	fmt.Fprintf(&b, "/Producer %s\n", pdfTextString(creatorName))
+	fmt.Fprintf(&b, "/CreationDate (%s)\n", pdfDate(m.Date))
+	fmt.Fprintf(&b, "/ModDate (%s)\n", pdfDate(m.Date))
+	b.WriteString(">>")
+	return b.String()
+}
+
+// pdfTextString encodes s as a UTF-16BE hex string so any script survives.
+func pdfTextString(s string) string {
+	var b strings.Builder
+	b.WriteString("<FEFF")
+	for _, r := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", r)
+	}
+	b.WriteString(">")
+	return b.String()
+}
+
+func pdfDate(t time.Time) string {
+	_, offset := t.Zone()
+	sign := '+'
+	if offset < 0 {
+		sign = '-'
+		offset = -offset
+	}
+	return fmt.Sprintf("D:%s%c%02d'%02d'", t.Format("20060102150405"), sign, offset/3600, offset%3600/60)
+}
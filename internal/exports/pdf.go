@@ -5,14 +5,19 @@ import (
 	"errors"
 	"fmt"
 	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"math"
 	"net/url"
+	"os"
 	"path"
-	"slices"
 	"strings"
 	"sync"
 
+	"github.com/disintegration/imaging"
 	"github.com/pwnholic/comdown/internal"
-	"github.com/signintech/gopdf"
+	_ "golang.org/x/image/webp"
 )
 
 const (
@@ -23,21 +28,44 @@ const (
 	maxMegapixels      = 25
 )
 
+// PDFGenerator streams pages into a spool file next to the output as they
+// are added, so a document of any length only keeps its page index in memory.
 type PDFGenerator struct {
-	pdf   *gopdf.GoPdf
-	mutex sync.Mutex
+	spool  *os.File
+	writer *pdfWriter
+	layout PageLayout
+	mutex  sync.Mutex
+	// pendingChapter is bookmarked on the next page that gets added.
+	pendingChapter string
+	metadata       Metadata
 }
 
-func NewPDFGenerator() *PDFGenerator {
-	pdf := &gopdf.GoPdf{}
-	pdf.Start(gopdf.Config{
-		Unit:     gopdf.UnitPT,
-		PageSize: *gopdf.PageSizeA4,
+func init() {
+	RegisterFormat(Format{
+		Name:      "pdf",
+		Extension: "pdf",
+		New:       func(opts Options) Exporter { return NewPDFGenerator(opts.Layout) },
 	})
-	return &PDFGenerator{pdf: pdf}
 }
 
-func (p *PDFGenerator) AddImageToPDF(imgBytes []byte, fileName, rawURL string) error {
+func NewPDFGenerator(layout PageLayout) *PDFGenerator {
+	return &PDFGenerator{layout: layout}
+}
+
+func (p *PDFGenerator) SetMetadata(meta Metadata) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.metadata = meta
+}
+
+// AddChapter bookmarks the next page in the document outline.
+func (p *PDFGenerator) AddChapter(title string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.pendingChapter = title
+}
+
+func (p *PDFGenerator) AddImage(imgBytes []byte, fileName, rawURL string) error {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
 
@@ -46,47 +74,108 @@ func (p *PDFGenerator) AddImageToPDF(imgBytes []byte, fileName, rawURL string) e
 		return nil
 	}
 
-	img, format, err := image.Decode(bytes.NewReader(imgBytes))
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(imgBytes))
 	if err != nil {
 		internal.WarningLog("Failed to decode image: %v\n", err)
 		return nil
 	}
 
-	bounds := img.Bounds()
-	width, height := bounds.Dx(), bounds.Dy()
-
-	validFormats := []string{"jpg", "jpeg", "webp"}
-	if !slices.Contains(validFormats, strings.ToLower(format)) {
-		internal.WarningLog("Skipping unsupported image format: %s (only jpg/jpeg/webp allowed)\n", format)
-		return nil
+	// JPEGs are embedded as downloaded, everything else is decoded and
+	// embedded as lossless pixel data.
+	var addPage func(box pageBox) (int, error)
+	if strings.ToLower(format) == "jpeg" {
+		if imgBytes, cfg, err = limitImageSize(imgBytes, cfg); err != nil {
+			internal.WarningLog("%v\n", err)
+			return nil
+		}
+		addPage = func(box pageBox) (int, error) { return p.writer.addJPEGPage(imgBytes, cfg, box) }
+	} else {
+		img, _, err := image.Decode(bytes.NewReader(imgBytes))
+		if err != nil {
+			internal.WarningLog("Failed to decode %s image: %v\n", format, err)
+			return nil
+		}
+		img = limitPixelSize(img)
+		cfg.Width, cfg.Height = img.Bounds().Dx(), img.Bounds().Dy()
+		addPage = func(box pageBox) (int, error) { return p.writer.addPixelPage(img, box) }
 	}
 
-	if err := p.addImageToPage(imgBytes, width, height); err != nil {
-		internal.WarningLog("%v\n", err)
-		return nil
+	if err := p.addImageToPage(cfg, fileName, addPage); err != nil {
+		return err
 	}
 
-	logImageInfo(format, width, height, rawURL, fileName)
+	logImageInfo(format, cfg.Width, cfg.Height, rawURL, fileName)
 	return nil
 }
 
-func (p *PDFGenerator) addImageToPage(imgBytes []byte, width, height int) error {
-	imageHolder, err := gopdf.ImageHolderByBytes(imgBytes)
+func (p *PDFGenerator) addImageToPage(cfg image.Config, fileName string, addPage func(box pageBox) (int, error)) error {
+	if p.writer == nil {
+		spool, err := createSpool(fileName)
+		if err != nil {
+			return err
+		}
+		writer, err := newPDFWriter(spool)
+		if err != nil {
+			discardSpool(spool)
+			return fmt.Errorf("failed to start PDF: %w", err)
+		}
+		p.spool, p.writer = spool, writer
+	}
+
+	pageID, err := addPage(p.layout.place(cfg.Width, cfg.Height))
 	if err != nil {
-		return fmt.Errorf("failed to create PDF image holder: %w", err)
+		return fmt.Errorf("failed to add image to PDF: %w", err)
 	}
 
-	pageSize := &gopdf.Rect{
-		W: float64(width)*ptsPerInch/dpi - 1,
-		H: float64(height)*ptsPerInch/dpi - 1,
+	if p.pendingChapter != "" {
+		p.writer.addBookmark(p.pendingChapter, pageID)
+		p.pendingChapter = ""
 	}
+	return nil
+}
 
-	p.pdf.AddPageWithOption(gopdf.PageOption{PageSize: pageSize})
-	if err := p.pdf.ImageByHolder(imageHolder, 0, 0, nil); err != nil {
-		return fmt.Errorf("failed to add image to PDF: %w", err)
+// downscaleFactor is how much an image has to shrink to stay within
+// maxImageSize wide and maxMegapixels in area. Height alone is not limited,
+// long webtoon strips are legitimately tall and -slice is the way to cut them.
+func downscaleFactor(width, height int) float64 {
+	w, h := float64(width), float64(height)
+	return min(1, maxImageSize/w, math.Sqrt(maxMegapixels*1e6/(w*h)))
+}
+
+// limitImageSize scales down oversized JPEGs before they are embedded.
+func limitImageSize(imgBytes []byte, cfg image.Config) ([]byte, image.Config, error) {
+	scale := downscaleFactor(cfg.Width, cfg.Height)
+	if scale >= 1 {
+		return imgBytes, cfg, nil
 	}
 
-	return nil
+	img, err := jpeg.Decode(bytes.NewReader(imgBytes))
+	if err != nil {
+		return nil, cfg, fmt.Errorf("failed to decode oversized image: %w", err)
+	}
+	img = imaging.Resize(img, int(float64(cfg.Width)*scale), int(float64(cfg.Height)*scale), imaging.Lanczos)
+
+	buff := new(bytes.Buffer)
+	if err := jpeg.Encode(buff, img, &jpeg.Options{Quality: defaultJPEGQuality}); err != nil {
+		return nil, cfg, fmt.Errorf("failed to encode downscaled image: %w", err)
+	}
+	internal.InfoLog("Downscaled %dx%d image to %dx%d\n", cfg.Width, cfg.Height, img.Bounds().Dx(), img.Bounds().Dy())
+
+	cfg.Width, cfg.Height = img.Bounds().Dx(), img.Bounds().Dy()
+	cfg.ColorModel = img.ColorModel()
+	return buff.Bytes(), cfg, nil
+}
+
+// limitPixelSize is limitImageSize for decoded lossless images.
+func limitPixelSize(img image.Image) image.Image {
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	scale := downscaleFactor(width, height)
+	if scale >= 1 {
+		return img
+	}
+	resized := imaging.Resize(img, int(float64(width)*scale), int(float64(height)*scale), imaging.Lanczos)
+	internal.InfoLog("Downscaled %dx%d image to %dx%d\n", width, height, resized.Bounds().Dx(), resized.Bounds().Dy())
+	return resized
 }
 
 func logImageInfo(format string, width, height int, rawURL, fileName string) {
@@ -100,34 +189,43 @@ func logImageInfo(format string, width, height int, rawURL, fileName string) {
 		format, width, height, lastSegment, fileName)
 }
 
-func (p *PDFGenerator) SavePDF(outputPath string) error {
+func (p *PDFGenerator) Save(outputPath string) error {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
-	if p.pdf == nil {
-		return errors.New("PDF not initialized")
+
+	if p.writer == nil {
+		return errors.New("PDF has no pages")
+	}
+	// Merged volumes open with a title page and table of contents.
+	if len(p.writer.outline) > 0 {
+		if err := p.writer.addContentsPages(p.metadata, p.layout); err != nil {
+			return fmt.Errorf("failed to write contents page: %w", err)
+		}
+	}
+	if err := p.writer.finish(p.metadata); err != nil {
+		return fmt.Errorf("failed to write PDF: %w", err)
 	}
-	return p.pdf.WritePdf(outputPath)
+	if err := commitSpool(p.spool, outputPath); err != nil {
+		return err
+	}
+	p.spool, p.writer = nil, nil
+	return nil
 }
 
+// Close drops a document that was started but never saved.
 func (p *PDFGenerator) Close() {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
 
-	if p.pdf != nil {
-		p.pdf.Close()
-		p.pdf = nil
-	}
+	discardSpool(p.spool)
+	p.spool, p.writer = nil, nil
 }
 
 func (p *PDFGenerator) Reset() {
+	p.Close()
+
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
-
-	newPdf := &gopdf.GoPdf{}
-	newPdf.Start(gopdf.Config{
-		Unit:     gopdf.UnitPT,
-		PageSize: *gopdf.PageSizeA4,
-	})
-	newPdf.SetCompressLevel(0)
-	p.pdf = newPdf
+	p.pendingChapter = ""
+	p.metadata = Metadata{}
 }
@@ -0,0 +1,320 @@
+package exports
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+const (
+	pdfCatalogID = 1
+	pdfPagesID   = 2
+)
+
+type pdfOutlineEntry struct {
+	title  string
+	pageID int
+}
+
+// pdfWriter streams a PDF to a file one page at a time. Image data goes to
+// disk as soon as a page is added and only object offsets, page references
+// and bookmarks stay in memory, so memory use does not depend on how many
+// pages the document has.
+type pdfWriter struct {
+	buf     *bufio.Writer
+	offset  int64
+	offsets []int64
+	pages   []int
+	outline []pdfOutlineEntry
+}
+
+func newPDFWriter(out io.Writer) (*pdfWriter, error) {
+	w := &pdfWriter{
+		buf: bufio.NewWriter(out),
+		// The catalog and page tree are written last but their numbers are
+		// fixed so pages can point at their parent right away.
+		offsets: make([]int64, 2),
+	}
+	if _, err := w.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n"); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *pdfWriter) Write(p []byte) (int, error) {
+	n, err := w.buf.Write(p)
+	w.offset += int64(n)
+	return n, err
+}
+
+func (w *pdfWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *pdfWriter) newObject() int {
+	w.offsets = append(w.offsets, 0)
+	return len(w.offsets)
+}
+
+func (w *pdfWriter) beginObject(id int) error {
+	w.offsets[id-1] = w.offset
+	_, err := fmt.Fprintf(w, "%d 0 obj\n", id)
+	return err
+}
+
+func (w *pdfWriter) writeObject(id int, body string) error {
+	if err := w.beginObject(id); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%s\nendobj\n", body)
+	return err
+}
+
+func (w *pdfWriter) writeStream(id int, dict string, data []byte) error {
+	if err := w.beginObject(id); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "<< %s /Length %d >>\nstream\n", dict, len(data)); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	_, err := w.WriteString("\nendstream\nendobj\n")
+	return err
+}
+
+// addJPEGPage writes a page laid out by box showing the JPEG.
+func (w *pdfWriter) addJPEGPage(jpegBytes []byte, cfg image.Config, box pageBox) (int, error) {
+	imageID, err := w.writeJPEGImage(jpegBytes, cfg)
+	if err != nil {
+		return 0, err
+	}
+	return w.addImagePage(imageID, box)
+}
+
+// addPixelPage writes a page laid out by box showing img as lossless pixels.
+func (w *pdfWriter) addPixelPage(img image.Image, box pageBox) (int, error) {
+	imageID, err := w.writePixelImage(img)
+	if err != nil {
+		return 0, err
+	}
+	return w.addImagePage(imageID, box)
+}
+
+// writeJPEGImage writes the JPEG as an image XObject. The JPEG is embedded as
+// is, PDF readers decode it.
+func (w *pdfWriter) writeJPEGImage(jpegBytes []byte, cfg image.Config) (int, error) {
+	colorSpace, decode := "/DeviceRGB", ""
+	switch cfg.ColorModel {
+	case color.GrayModel, color.Gray16Model:
+		colorSpace = "/DeviceGray"
+	case color.CMYKModel:
+		// Adobe writes CMYK JPEGs inverted, which is what nearly every
+		// CMYK JPEG in the wild is.
+		colorSpace, decode = "/DeviceCMYK", " /Decode [1 0 1 0 1 0 1 0]"
+	}
+
+	imageID := w.newObject()
+	dict := fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace %s /BitsPerComponent 8 /Filter /DCTDecode%s",
+		cfg.Width, cfg.Height, colorSpace, decode)
+	return imageID, w.writeStream(imageID, dict, jpegBytes)
+}
+
+// writePixelImage writes img as Flate compressed pixels, so PNG and other
+// lossless sources keep every pixel. Transparency is kept as a soft mask.
+func (w *pdfWriter) writePixelImage(img image.Image) (int, error) {
+	pixels := imaging.Clone(img)
+	width, height := pixels.Bounds().Dx(), pixels.Bounds().Dy()
+
+	colors, colorSpace := 3, "/DeviceRGB"
+	switch img.ColorModel() {
+	case color.GrayModel, color.Gray16Model:
+		colors, colorSpace = 1, "/DeviceGray"
+	}
+
+	samples := make([]byte, 0, width*height*colors)
+	alpha := make([]byte, 0, width*height)
+	opaque := true
+	for i := 0; i < len(pixels.Pix); i += 4 {
+		samples = append(samples, pixels.Pix[i:i+colors]...)
+		alpha = append(alpha, pixels.Pix[i+3])
+		opaque = opaque && pixels.Pix[i+3] == 255
+	}
+
+	smask := ""
+	if !opaque {
+		maskID := w.newObject()
+		if err := w.writeFlateImage(maskID, width, height, 1, "/DeviceGray", "", alpha); err != nil {
+			return 0, err
+		}
+		smask = fmt.Sprintf(" /SMask %d 0 R", maskID)
+	}
+
+	imageID := w.newObject()
+	return imageID, w.writeFlateImage(imageID, width, height, colors, colorSpace, smask, samples)
+}
+
+// writeFlateImage writes 8 bit samples as an image XObject. Rows go through
+// the PNG Paeth predictor before compression, readers undo it through
+// /DecodeParms.
+func (w *pdfWriter) writeFlateImage(id, width, height, colors int, colorSpace, extra string, samples []byte) error {
+	stride := width * colors
+	buff := new(bytes.Buffer)
+	zw := zlib.NewWriter(buff)
+	row := make([]byte, stride+1)
+	row[0] = 4 // Paeth
+	prev := make([]byte, stride)
+	for y := range height {
+		cur := samples[y*stride : (y+1)*stride]
+		for i := range stride {
+			var left, upLeft byte
+			if i >= colors {
+				left, upLeft = cur[i-colors], prev[i-colors]
+			}
+			row[i+1] = cur[i] - paeth(left, prev[i], upLeft)
+		}
+		if _, err := zw.Write(row); err != nil {
+			return fmt.Errorf("failed to compress image: %w", err)
+		}
+		prev = cur
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to compress image: %w", err)
+	}
+
+	dict := fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace %s /BitsPerComponent 8%s /Filter /FlateDecode /DecodeParms << /Predictor 15 /Colors %d /BitsPerComponent 8 /Columns %d >>",
+		width, height, colorSpace, extra, colors, width)
+	return w.writeStream(id, dict, buff.Bytes())
+}
+
+func paeth(left, up, upLeft byte) byte {
+	p := int(left) + int(up) - int(upLeft)
+	pl, pu, pul := absDiff(p, int(left)), absDiff(p, int(up)), absDiff(p, int(upLeft))
+	switch {
+	case pl <= pu && pl <= pul:
+		return left
+	case pu <= pul:
+		return up
+	default:
+		return upLeft
+	}
+}
+
+// addImagePage writes the page that draws an already written image XObject
+// into box.
+func (w *pdfWriter) addImagePage(imageID int, box pageBox) (int, error) {
+	var content strings.Builder
+	if box.background != nil {
+		bg := box.background
+		fmt.Fprintf(&content, "q %.3f %.3f %.3f rg 0 0 %.2f %.2f re f Q\n",
+			float64(bg.R)/255, float64(bg.G)/255, float64(bg.B)/255, box.width, box.height)
+	}
+	fmt.Fprintf(&content, "q %.2f 0 0 %.2f %.2f %.2f cm /Im0 Do Q", box.w, box.h, box.x, box.y)
+
+	contentID := w.newObject()
+	if err := w.writeStream(contentID, "", []byte(content.String())); err != nil {
+		return 0, err
+	}
+
+	pageID := w.newObject()
+	page := fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /XObject << /Im0 %d 0 R >> >> /Contents %d 0 R >>",
+		pdfPagesID, box.width, box.height, imageID, contentID)
+	if err := w.writeObject(pageID, page); err != nil {
+		return 0, err
+	}
+
+	w.pages = append(w.pages, pageID)
+	return pageID, nil
+}
+
+// addBookmark adds a top-level outline entry for an already written page.
+func (w *pdfWriter) addBookmark(title string, pageID int) {
+	w.outline = append(w.outline, pdfOutlineEntry{title: title, pageID: pageID})
+}
+
+// finish writes the page tree, outline, metadata and cross-reference table
+// and flushes everything to the file.
+func (w *pdfWriter) finish(meta Metadata) error {
+	catalog := fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R", pdfPagesID)
+
+	if len(w.outline) > 0 {
+		outlinesID, err := w.writeOutline()
+		if err != nil {
+			return err
+		}
+		catalog += fmt.Sprintf(" /Outlines %d 0 R /PageMode /UseOutlines", outlinesID)
+	}
+
+	infoID := 0
+	if !meta.IsZero() {
+		xmp := meta.xmpPacket()
+		metadataID := w.newObject()
+		if err := w.writeStream(metadataID, "/Type /Metadata /Subtype /XML", []byte(xmp)); err != nil {
+			return err
+		}
+		catalog += fmt.Sprintf(" /Metadata %d 0 R", metadataID)
+
+		infoID = w.newObject()
+		if err := w.writeObject(infoID, meta.pdfInfo()); err != nil {
+			return err
+		}
+	}
+
+	var kids strings.Builder
+	for _, id := range w.pages {
+		fmt.Fprintf(&kids, "%d 0 R ", id)
+	}
+	pages := fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.TrimSpace(kids.String()), len(w.pages))
+	if err := w.writeObject(pdfPagesID, pages); err != nil {
+		return err
+	}
+	if err := w.writeObject(pdfCatalogID, catalog+" >>"); err != nil {
+		return err
+	}
+
+	xrefOffset := w.offset
+	fmt.Fprintf(w, "xref\n0 %d\n0000000000 65535 f \n", len(w.offsets)+1)
+	for _, offset := range w.offsets {
+		fmt.Fprintf(w, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(w, "trailer\n<< /Size %d /Root %d 0 R", len(w.offsets)+1, pdfCatalogID)
+	if infoID > 0 {
+		fmt.Fprintf(w, " /Info %d 0 R", infoID)
+	}
+	if _, err := fmt.Fprintf(w, " >>\nstartxref\n%d\n%%%%EOF\n", xrefOffset); err != nil {
+		return err
+	}
+	return w.buf.Flush()
+}
+
+func (w *pdfWriter) writeOutline() (int, error) {
+	outlinesID := w.newObject()
+	ids := make([]int, len(w.outline))
+	for i := range w.outline {
+		ids[i] = w.newObject()
+	}
+
+	for i, entry := range w.outline {
+		item := fmt.Sprintf("<< /Title %s /Parent %d 0 R /Dest [%d 0 R /Fit]", pdfTextString(entry.title), outlinesID, entry.pageID)
+		if i > 0 {
+			item += fmt.Sprintf(" /Prev %d 0 R", ids[i-1])
+		}
+		if i < len(ids)-1 {
+			item += fmt.Sprintf(" /Next %d 0 R", ids[i+1])
+		}
+		if err := w.writeObject(ids[i], item+" >>"); err != nil {
+			return 0, err
+		}
+	}
+
+	outlines := fmt.Sprintf("<< /Type /Outlines /First %d 0 R /Last %d 0 R /Count %d >>", ids[0], ids[len(ids)-1], len(ids))
+	return outlinesID, w.writeObject(outlinesID, outlines)
+}
@@ -0,0 +1,402 @@
+package exports
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ledongthuc/pdf"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+)
+
+func testJPEG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height)), nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// writeTestPDF writes one page per entry of pages, bookmarking the pages
+// whose title is not empty.
+func writeTestPDF(t *testing.T, pages []string, meta Metadata) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	w, err := newPDFWriter(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jpegBytes := testJPEG(t, 8, 12)
+	cfg := image.Config{ColorModel: color.YCbCrModel, Width: 8, Height: 12}
+	for i, title := range pages {
+		box := PageLayout{}.place(8, 12)
+		var pageID int
+		if i%2 == 0 {
+			pageID, err = w.addJPEGPage(jpegBytes, cfg, box)
+		} else {
+			pageID, err = w.addPixelPage(image.NewNRGBA(image.Rect(0, 0, 8, 12)), box)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if title != "" {
+			w.addBookmark(title, pageID)
+		}
+	}
+	if err := w.finish(meta); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+var pdfStartXref = regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`)
+
+func TestPDFWriterXrefOffsets(t *testing.T) {
+	tests := []struct {
+		name  string
+		pages []string
+		meta  Metadata
+	}{
+		{name: "single page", pages: []string{""}},
+		{name: "mixed pages", pages: []string{"", "", ""}},
+		{name: "bookmarks and metadata", pages: []string{"Chapter 1", "", "Chapter 2"}, meta: Metadata{Series: "Test", Chapter: "1-2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := writeTestPDF(t, tt.pages, tt.meta)
+
+			match := pdfStartXref.FindSubmatch(data)
+			if match == nil {
+				t.Fatal("no startxref at the end of the file")
+			}
+			xref, _ := strconv.Atoi(string(match[1]))
+			if !bytes.HasPrefix(data[xref:], []byte("xref\n0 ")) {
+				t.Fatalf("startxref %d does not point at the xref table", xref)
+			}
+
+			lines := strings.Split(string(data[xref:]), "\n")
+			var count int
+			fmt.Sscanf(lines[1], "0 %d", &count)
+			if count < 3 {
+				t.Fatalf("xref table has %d entries", count)
+			}
+			for id := 1; id < count; id++ {
+				entry := lines[2+id]
+				offset, err := strconv.Atoi(entry[:10])
+				if err != nil {
+					t.Fatalf("bad xref entry %q", entry)
+				}
+				if want := fmt.Sprintf("%d 0 obj\n", id); !bytes.HasPrefix(data[offset:], []byte(want)) {
+					t.Errorf("object %d: offset %d points at %q", id, offset, data[offset:offset+min(12, len(data)-offset)])
+				}
+			}
+			if want := fmt.Sprintf("/Count %d >>", len(tt.pages)); !bytes.Contains(data, []byte(want)) {
+				t.Errorf("page tree lacks %s", want)
+			}
+		})
+	}
+}
+
+func TestPDFWriterOutline(t *testing.T) {
+	tests := []struct {
+		name  string
+		pages []string
+		want  []string
+	}{
+		{name: "no bookmarks", pages: []string{"", ""}},
+		{name: "one bookmark", pages: []string{"Chapter 1", ""}, want: []string{"Chapter 1"}},
+		{name: "three bookmarks", pages: []string{"Chapter 1", "Chapter 2", "", "Chapter 3"}, want: []string{"Chapter 1", "Chapter 2", "Chapter 3"}},
+		{name: "non-Latin title", pages: []string{"第1話"}, want: []string{"第1話"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := string(writeTestPDF(t, tt.pages, Metadata{}))
+
+			if len(tt.want) == 0 {
+				if strings.Contains(data, "/Outlines") {
+					t.Error("document without bookmarks has an outline")
+				}
+				return
+			}
+			if !strings.Contains(data, "/PageMode /UseOutlines") {
+				t.Error("catalog does not open the outline")
+			}
+			root := regexp.MustCompile(fmt.Sprintf(`/Type /Outlines /First \d+ 0 R /Last \d+ 0 R /Count %d >>`, len(tt.want)))
+			if !root.MatchString(data) {
+				t.Errorf("outline root does not count %d entries", len(tt.want))
+			}
+			for i, title := range tt.want {
+				item := regexp.MustCompile(regexp.QuoteMeta("<< /Title "+pdfTextString(title)) + `[^\n]*`).FindString(data)
+				if item == "" {
+					t.Fatalf("no outline entry for %q", title)
+				}
+				if hasPrev := strings.Contains(item, "/Prev"); hasPrev != (i > 0) {
+					t.Errorf("entry %q: /Prev present is %v", title, hasPrev)
+				}
+				if hasNext := strings.Contains(item, "/Next"); hasNext != (i < len(tt.want)-1) {
+					t.Errorf("entry %q: /Next present is %v", title, hasNext)
+				}
+			}
+		})
+	}
+}
+
+func TestPDFGeneratorSpool(t *testing.T) {
+	tests := []struct {
+		name string
+		save bool
+	}{
+		{name: "saved", save: true},
+		{name: "closed", save: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			outputPath := filepath.Join(dir, "01.pdf")
+			p := NewPDFGenerator(PageLayout{})
+			for range 3 {
+				if err := p.AddImage(testJPEG(t, 8, 12), outputPath, "https://example.com/1.jpg"); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if _, err := os.Stat(outputPath); err == nil {
+				t.Fatal("output exists before Save")
+			}
+			parts, _ := filepath.Glob(filepath.Join(dir, ".comdown-*.part"))
+			if len(parts) != 1 {
+				t.Fatalf("found %d spool files while writing, want 1", len(parts))
+			}
+
+			if tt.save {
+				if err := p.Save(outputPath); err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				p.Close()
+			}
+
+			parts, _ = filepath.Glob(filepath.Join(dir, ".comdown-*.part"))
+			if len(parts) != 0 {
+				t.Errorf("spool files left behind: %v", parts)
+			}
+			data, err := os.ReadFile(outputPath)
+			if !tt.save {
+				if err == nil {
+					t.Error("closed document was written")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.HasPrefix(data, []byte("%PDF-")) || !pdfStartXref.Match(data) {
+				t.Error("saved file is not a complete PDF")
+			}
+			if info, _ := os.Stat(outputPath); info.Mode().Perm() != 0o644 {
+				t.Errorf("saved file has mode %v, want 0644", info.Mode().Perm())
+			}
+		})
+	}
+}
+
+// readBackPDF opens data with an independent PDF reader and walks every
+// object reachable from the trailer, so an xref offset that does not point
+// at its object or a stream whose /Length is off fails the test.
+func readBackPDF(t *testing.T, data []byte) *pdf.Reader {
+	t.Helper()
+	r, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("PDF does not open: %v", err)
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			t.Fatalf("PDF does not read back: %v", err)
+		}
+	}()
+	walkPDF(t, r.Trailer(), "trailer")
+	return r
+}
+
+// walkPDF resolves v and everything below it. Links back up the tree are
+// not followed, they lead to objects that are reached anyway.
+func walkPDF(t *testing.T, v pdf.Value, at string) {
+	t.Helper()
+	switch v.Kind() {
+	case pdf.Array:
+		for i := range v.Len() {
+			walkPDF(t, v.Index(i), fmt.Sprintf("%s[%d]", at, i))
+		}
+	case pdf.Stream:
+		readPDFStream(t, v, at)
+		fallthrough
+	case pdf.Dict:
+		for _, key := range v.Keys() {
+			switch key {
+			case "Parent", "Prev", "Last":
+				continue
+			}
+			walkPDF(t, v.Key(key), at+"/"+key)
+		}
+	}
+}
+
+// readPDFStream reads the stream at its /Length. Streams the reader cannot
+// decode, JPEGs and predictor compressed pixels, are only checked through
+// their dictionary.
+func readPDFStream(t *testing.T, v pdf.Value, at string) []byte {
+	t.Helper()
+	switch filter := v.Key("Filter").Name(); {
+	case filter == "DCTDecode", v.Key("DecodeParms").Kind() != pdf.Null:
+		return nil
+	case filter != "" && filter != "FlateDecode":
+		t.Fatalf("%s: unexpected filter %s", at, filter)
+	}
+	data, err := io.ReadAll(v.Reader())
+	if err != nil {
+		t.Fatalf("%s: stream does not read back: %v", at, err)
+	}
+	return data
+}
+
+func TestPDFWriterReadBack(t *testing.T) {
+	tests := []struct {
+		name  string
+		pages []string
+		meta  Metadata
+	}{
+		{name: "single page", pages: []string{""}},
+		{name: "mixed pages", pages: []string{"", "", "", ""}},
+		{name: "bookmarks and metadata", pages: []string{"Chapter 1", "", "第2話"}, meta: Metadata{Series: "Test", Chapter: "1-2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := readBackPDF(t, writeTestPDF(t, tt.pages, tt.meta))
+
+			if r.NumPage() != len(tt.pages) {
+				t.Fatalf("read %d pages, want %d", r.NumPage(), len(tt.pages))
+			}
+			box := PageLayout{}.place(8, 12)
+			for i := range tt.pages {
+				page := r.Page(i + 1)
+				if page.V.IsNull() {
+					t.Fatalf("page %d not found", i+1)
+				}
+				media := page.V.Key("MediaBox")
+				if media.Len() != 4 || media.Index(2).Float64() != box.width || media.Index(3).Float64() != box.height {
+					t.Errorf("page %d: MediaBox %v, want [0 0 %.2f %.2f]", i+1, media, box.width, box.height)
+				}
+				content := string(readPDFStream(t, page.V.Key("Contents"), "contents"))
+				if want := fmt.Sprintf("q %.2f 0 0 %.2f 0.00 0.00 cm /Im0 Do Q", box.w, box.h); content != want {
+					t.Errorf("page %d: content %q, want %q", i+1, content, want)
+				}
+				img := page.Resources().Key("XObject").Key("Im0")
+				if img.Key("Subtype").Name() != "Image" || img.Key("Width").Int64() != 8 || img.Key("Height").Int64() != 12 {
+					t.Errorf("page %d: image %v is not the 8x12 image drawn", i+1, img)
+				}
+			}
+
+			var titles []string
+			for _, entry := range r.Outline().Child {
+				titles = append(titles, entry.Title)
+			}
+			var want []string
+			for _, title := range tt.pages {
+				if title != "" {
+					want = append(want, title)
+				}
+			}
+			if !slices.Equal(titles, want) {
+				t.Errorf("outline %q, want %q", titles, want)
+			}
+
+			info := r.Trailer().Key("Info")
+			if tt.meta.IsZero() {
+				if !info.IsNull() {
+					t.Error("document without metadata has an info dictionary")
+				}
+				return
+			}
+			if got := info.Key("Title").Text(); got != tt.meta.Title() {
+				t.Errorf("info title %q, want %q", got, tt.meta.Title())
+			}
+			xmp := string(readPDFStream(t, r.Trailer().Key("Root").Key("Metadata"), "metadata"))
+			if xmp != tt.meta.xmpPacket() {
+				t.Error("XMP metadata does not read back whole")
+			}
+		})
+	}
+}
+
+func TestPDFGeneratorContentsReadBack(t *testing.T) {
+	outputPath := filepath.Join(t.TempDir(), "01.pdf")
+	p := NewPDFGenerator(PageLayout{Mode: LayoutFit, Width: 200, Height: 300, Background: color.RGBA{R: 255, G: 255, B: 255, A: 255}})
+	p.SetMetadata(Metadata{Series: "Test Series", Chapter: "1-40", Cover: testJPEG(t, 20, 30)})
+	// Enough chapters to run the table of contents over two pages.
+	const chapters = 40
+	for i := range chapters {
+		p.AddChapter(fmt.Sprintf("Chapter %d", i+1))
+		if err := p.AddImage(testJPEG(t, 8, 12), outputPath, "https://example.com/1.jpg"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.Save(outputPath); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := readBackPDF(t, data)
+
+	contentsPages := r.NumPage() - chapters
+	if contentsPages < 2 {
+		t.Fatalf("read %d pages for %d chapters, want the contents to take at least two", r.NumPage(), chapters)
+	}
+	var links int
+	for n := 1; n <= contentsPages; n++ {
+		page := r.Page(n)
+		if page.Resources().Key("Font").Key("F1").Kind() != pdf.Dict {
+			t.Errorf("contents page %d has no font", n)
+		}
+		annots := page.V.Key("Annots")
+		for i := range annots.Len() {
+			dest := annots.Index(i).Key("Dest").Index(0)
+			if dest.Key("Type").Name() != "Page" {
+				t.Errorf("contents page %d: link %d does not lead to a page", n, i)
+			}
+			links++
+		}
+	}
+	if links != chapters {
+		t.Errorf("contents link %d chapters, want %d", links, chapters)
+	}
+	if got := len(r.Outline().Child); got != chapters {
+		t.Errorf("outline has %d entries, want %d", got, chapters)
+	}
+
+	// Only the glyphs the contents pages use are embedded.
+	fontFile := r.Page(1).Resources().Key("Font").Key("F1").Key("DescendantFonts").Index(0).Key("FontDescriptor").Key("FontFile2")
+	data = readPDFStream(t, fontFile, "FontFile2")
+	if int64(len(data)) != fontFile.Key("Length1").Int64() {
+		t.Errorf("font file is %d bytes, /Length1 says %d", len(data), fontFile.Key("Length1").Int64())
+	}
+	if len(data) > len(goregular.TTF)/4 {
+		t.Errorf("embedded font is %d bytes of %d, want a subset", len(data), len(goregular.TTF))
+	}
+	if _, err := sfnt.Parse(data); err != nil {
+		t.Errorf("embedded font does not parse: %v", err)
+	}
+}
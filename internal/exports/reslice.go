@@ -0,0 +1,241 @@
+package exports
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"sync"
+
+	"github.com/disintegration/imaging"
+	"github.com/pwnholic/comdown/internal"
+)
+
+const (
+	// gutterTolerance is how far a channel may drift along a row for the row
+	// to still count as blank background.
+	gutterTolerance = 12
+	// blankRowMargin keeps a few blank rows on both sides of a cut so panel
+	// borders are not shaved off.
+	blankRowMargin = 2
+)
+
+// reslicer stitches the images of a chapter into one long strip and cuts it
+// again into pages of roughly the same height, preferring blank gutters
+// between panels. It sits in front of any exporter, so every format gets the
+// same pages.
+type reslicer struct {
+	Exporter
+	height   int
+	window   int
+	quality  int
+	lossless bool
+	strip    *image.RGBA
+	// losslessStrip is set once a PNG or other lossless image went into the
+	// pending strip, the pages cut from it are then kept lossless too.
+	losslessStrip bool
+	// fileName and rawURL describe the image the pending strip ends with,
+	// they are only used for logging.
+	fileName string
+	rawURL   string
+	mutex    sync.Mutex
+}
+
+func newReslicer(inner Exporter, opts Options) *reslicer {
+	quality := opts.JPEGQuality
+	if quality == 0 {
+		quality = defaultJPEGQuality
+	}
+	return &reslicer{
+		Exporter: inner,
+		height:   opts.SliceHeight,
+		window:   max(1, opts.SliceHeight/5),
+		quality:  quality,
+		lossless: opts.Lossless,
+	}
+}
+
+func (r *reslicer) AddChapter(title string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// Strips never run across chapters.
+	if err := r.flush(); err != nil {
+		internal.WarningLog("Failed to flush strip before chapter %s: %v\n", title, err)
+	}
+	r.Exporter.AddChapter(title)
+}
+
+func (r *reslicer) AddImage(imgBytes []byte, fileName, rawURL string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if len(imgBytes) == 0 {
+		internal.WarningLog("Skipping empty image data\n")
+		return nil
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(imgBytes))
+	if err != nil {
+		internal.WarningLog("Failed to decode image: %v\n", err)
+		return nil
+	}
+
+	r.fileName, r.rawURL = fileName, rawURL
+	r.losslessStrip = r.losslessStrip || format != "jpeg"
+	r.appendToStrip(img)
+
+	for r.strip.Bounds().Dy() >= r.height+r.window {
+		cut := r.findCut()
+		if err := r.emit(cut); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *reslicer) Save(outputPath string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if err := r.flush(); err != nil {
+		return err
+	}
+	return r.Exporter.Save(outputPath)
+}
+
+func (r *reslicer) Reset() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.strip = nil
+	r.losslessStrip = false
+	r.fileName, r.rawURL = "", ""
+	r.Exporter.Reset()
+}
+
+// appendToStrip adds img below the pending strip, scaled to the strip width
+// so mixed-width slices line up.
+func (r *reslicer) appendToStrip(img image.Image) {
+	if r.strip == nil {
+		r.strip = image.NewRGBA(image.Rect(0, 0, img.Bounds().Dx(), 0))
+	}
+
+	width := r.strip.Bounds().Dx()
+	if img.Bounds().Dx() != width {
+		img = imaging.Resize(img, width, 0, imaging.Lanczos)
+	}
+
+	oldHeight := r.strip.Bounds().Dy()
+	strip := image.NewRGBA(image.Rect(0, 0, width, oldHeight+img.Bounds().Dy()))
+	draw.Draw(strip, r.strip.Bounds(), r.strip, image.Point{}, draw.Src)
+	draw.Draw(strip, image.Rect(0, oldHeight, width, strip.Bounds().Dy()), img, img.Bounds().Min, draw.Src)
+	r.strip = strip
+}
+
+// findCut picks the row to end the next page at: the blank row closest to
+// the target height, or the target height itself when the window holds
+// nothing but artwork.
+func (r *reslicer) findCut() int {
+	height := r.strip.Bounds().Dy()
+	best := -1
+	for offset := 0; offset <= r.window; offset++ {
+		for _, y := range []int{r.height - offset, r.height + offset} {
+			if y <= 0 || y >= height {
+				continue
+			}
+			if r.isBlankRun(y-blankRowMargin, y+blankRowMargin) {
+				best = y
+				break
+			}
+		}
+		if best >= 0 {
+			return best
+		}
+	}
+	return min(r.height, height)
+}
+
+func (r *reslicer) isBlankRun(from, to int) bool {
+	height := r.strip.Bounds().Dy()
+	for y := max(0, from); y < min(height, to+1); y++ {
+		if !isBlankRow(r.strip, y) {
+			return false
+		}
+	}
+	return true
+}
+
+// isBlankRow reports whether every pixel of the row has about the same colour.
+func isBlankRow(img *image.RGBA, y int) bool {
+	row := img.Pix[y*img.Stride : y*img.Stride+img.Bounds().Dx()*4]
+	if len(row) == 0 {
+		return true
+	}
+	r0, g0, b0 := int(row[0]), int(row[1]), int(row[2])
+	for i := 4; i < len(row); i += 4 {
+		if absDiff(int(row[i]), r0) > gutterTolerance ||
+			absDiff(int(row[i+1]), g0) > gutterTolerance ||
+			absDiff(int(row[i+2]), b0) > gutterTolerance {
+			return false
+		}
+	}
+	return true
+}
+
+func absDiff(a, b int) int {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+// emit hands the first rows of the strip to the wrapped exporter as a page and
+// keeps the rest for the next page.
+func (r *reslicer) emit(cut int) error {
+	width, height := r.strip.Bounds().Dx(), r.strip.Bounds().Dy()
+	page := r.strip.SubImage(image.Rect(0, 0, width, cut))
+
+	rest := image.NewRGBA(image.Rect(0, 0, width, height-cut))
+	draw.Draw(rest, rest.Bounds(), r.strip, image.Point{0, cut}, draw.Src)
+
+	pageBytes, err := r.encodeSlice(page)
+	if err != nil {
+		return err
+	}
+	r.strip = rest
+	return r.Exporter.AddImage(pageBytes, r.fileName, r.rawURL)
+}
+
+// flush emits whatever is left of the strip as a final, shorter page. A tail
+// of nothing but background is dropped.
+func (r *reslicer) flush() error {
+	if r.strip == nil {
+		return nil
+	}
+	height := r.strip.Bounds().Dy()
+	if height > 0 && !r.isBlankRun(0, height-1) {
+		if err := r.emit(height); err != nil {
+			return err
+		}
+	}
+	r.strip = nil
+	r.losslessStrip = false
+	return nil
+}
+
+func (r *reslicer) encodeSlice(img image.Image) ([]byte, error) {
+	buff := new(bytes.Buffer)
+	if r.lossless && r.losslessStrip {
+		if err := png.Encode(buff, img); err != nil {
+			return nil, fmt.Errorf("failed to encode page slice: %w", err)
+		}
+		return buff.Bytes(), nil
+	}
+	if err := jpeg.Encode(buff, img, &jpeg.Options{Quality: r.quality}); err != nil {
+		return nil, fmt.Errorf("failed to encode page slice: %w", err)
+	}
+	return buff.Bytes(), nil
+}
@@ -0,0 +1,195 @@
+package exports
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"slices"
+	"testing"
+)
+
+// recordingExporter notes chapter marks and the height of every page it is
+// handed.
+type recordingExporter struct {
+	events []string
+}
+
+func (e *recordingExporter) SetMetadata(Metadata)    {}
+func (e *recordingExporter) AddChapter(title string) { e.events = append(e.events, "chapter "+title) }
+func (e *recordingExporter) Save(string) error       { return nil }
+func (e *recordingExporter) Reset()                  { e.events = nil }
+
+func (e *recordingExporter) AddImage(imgBytes []byte, _, _ string) error {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(imgBytes))
+	if err != nil {
+		return err
+	}
+	e.events = append(e.events, fmt.Sprint(cfg.Height))
+	return nil
+}
+
+// testStrip draws a strip of height rows. Rows for which shade returns a
+// value are that grey with every other pixel shifted by jitter, the others
+// are artwork of alternating black and white pixels.
+func testStrip(t *testing.T, height int, shade func(y int) (gray, jitter uint8, blank bool)) []byte {
+	t.Helper()
+	const width = 16
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for y := range height {
+		gray, jitter, blank := shade(y)
+		for x := range width {
+			switch {
+			case !blank && x%2 == 0:
+				img.SetGray(x, y, color.Gray{})
+			case !blank:
+				img.SetGray(x, y, color.Gray{Y: 255})
+			case x%2 == 0:
+				img.SetGray(x, y, color.Gray{Y: gray - jitter})
+			default:
+				img.SetGray(x, y, color.Gray{Y: gray})
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// gutters makes the rows in [from, to) pairs blank white, the rest artwork.
+func gutters(ranges ...[2]int) func(int) (uint8, uint8, bool) {
+	return func(y int) (uint8, uint8, bool) {
+		for _, r := range ranges {
+			if y >= r[0] && y < r[1] {
+				return 255, 0, true
+			}
+		}
+		return 0, 0, false
+	}
+}
+
+func TestReslicer(t *testing.T) {
+	// Pages are cut 100 rows tall, in a window of 20 rows either side.
+	const height = 100
+	art := gutters()
+	noisy := func(jitter uint8) func(int) (uint8, uint8, bool) {
+		return func(y int) (uint8, uint8, bool) {
+			if y >= 90 && y < 98 {
+				return 250, jitter, true
+			}
+			return 0, 0, false
+		}
+	}
+
+	type step struct {
+		chapter string
+		rows    int
+		shade   func(int) (uint8, uint8, bool)
+	}
+	tests := []struct {
+		name  string
+		steps []step
+		want  []string
+	}{
+		{
+			name:  "cut in a gutter above the target",
+			steps: []step{{rows: 250, shade: gutters([2]int{90, 98})}},
+			// The first row the margins leave blank from 100 upwards is 95.
+			want: []string{"95", "100", "55"},
+		},
+		{
+			name:  "cut in a gutter below the target",
+			steps: []step{{rows: 200, shade: gutters([2]int{108, 117})}},
+			want:  []string{"110", "90"},
+		},
+		{
+			name:  "no gutter is hard-cut at the height",
+			steps: []step{{rows: 300, shade: art}},
+			want:  []string{"100", "100", "100"},
+		},
+		{
+			name:  "gutter outside the window is ignored",
+			steps: []step{{rows: 250, shade: gutters([2]int{40, 50}, [2]int{150, 160})}},
+			want:  []string{"100", "100", "50"},
+		},
+		{
+			name:  "gutter within the tolerance",
+			steps: []step{{rows: 200, shade: noisy(gutterTolerance - 2)}},
+			want:  []string{"95", "105"},
+		},
+		{
+			name:  "gutter beyond the tolerance",
+			steps: []step{{rows: 200, shade: noisy(gutterTolerance + 3)}},
+			want:  []string{"100", "100"},
+		},
+		{
+			name: "strip carries over between images",
+			steps: []step{
+				{rows: 80, shade: art},
+				{rows: 120, shade: gutters([2]int{0, 20})},
+			},
+			want: []string{"97", "103"},
+		},
+		{
+			name:  "blank tail is dropped",
+			steps: []step{{rows: 140, shade: gutters([2]int{110, 140})}},
+			want:  []string{"112"},
+		},
+		{
+			name: "chapter change flushes the partial page",
+			steps: []step{
+				{chapter: "1"},
+				{rows: 150, shade: art},
+				{chapter: "2"},
+				{rows: 60, shade: art},
+				{rows: 60, shade: art},
+			},
+			want: []string{"chapter 1", "100", "50", "chapter 2", "100", "20"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &recordingExporter{}
+			r := newReslicer(inner, Options{SliceHeight: height, Lossless: true})
+			for _, s := range tt.steps {
+				if s.chapter != "" {
+					r.AddChapter(s.chapter)
+					continue
+				}
+				if err := r.AddImage(testStrip(t, s.rows, s.shade), "page", "https://example.com/page"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := r.Save("out"); err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(inner.events, tt.want) {
+				t.Errorf("pages %v, want %v", inner.events, tt.want)
+			}
+		})
+	}
+}
+
+func TestReslicerCutsInsideWindow(t *testing.T) {
+	const height = 100
+	// A single blank row at every position of the strip, the cut must land
+	// on it when it lies in the window and at the height otherwise.
+	for gutter := 60; gutter < 140; gutter++ {
+		inner := &recordingExporter{}
+		r := newReslicer(inner, Options{SliceHeight: height, Lossless: true})
+		shade := gutters([2]int{gutter - blankRowMargin, gutter + blankRowMargin + 1})
+		if err := r.AddImage(testStrip(t, 200, shade), "page", "https://example.com/page"); err != nil {
+			t.Fatal(err)
+		}
+		want := fmt.Sprint(height)
+		if gutter >= height-r.window && gutter <= height+r.window {
+			want = fmt.Sprint(gutter)
+		}
+		if len(inner.events) == 0 || inner.events[0] != want {
+			t.Errorf("gutter at %d: first page %v, want %s", gutter, inner.events, want)
+		}
+	}
+}
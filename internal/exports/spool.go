@@ -0,0 +1,108 @@
+package exports
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// createSpool opens a hidden temporary file next to outputPath. Exporters
+// stream pages into it while they arrive and rename it into place on Save,
+// so a merged volume never has to fit in memory and a crash never leaves a
+// half written file under the final name.
+func createSpool(outputPath string) (*os.File, error) {
+	dir := filepath.Dir(outputPath)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+	file, err := os.CreateTemp(dir, ".comdown-*.part")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create spool file: %w", err)
+	}
+	return file, nil
+}
+
+// commitSpool closes the spool file and moves it to outputPath.
+func commitSpool(file *os.File, outputPath string) error {
+	// CreateTemp makes the file private, finished documents are not.
+	if err := file.Chmod(0o644); err != nil {
+		return fmt.Errorf("failed to set output permissions: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close spool file: %w", err)
+	}
+	if err := os.Rename(file.Name(), outputPath); err == nil {
+		return nil
+	}
+	if err := copyFile(file.Name(), outputPath); err != nil {
+		return err
+	}
+	return os.Remove(file.Name())
+}
+
+// discardSpool drops an unfinished spool file.
+func discardSpool(file *os.File) {
+	if file == nil {
+		return
+	}
+	_ = file.Close()
+	_ = os.Remove(file.Name())
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open spool file: %w", err)
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, in); err != nil {
+		return fmt.Errorf("failed to copy spool file: %w", err)
+	}
+	return out.Close()
+}
+
+// zipSpool is a zip archive streamed entry by entry into a spool file.
+type zipSpool struct {
+	file    *os.File
+	archive *zip.Writer
+}
+
+func createZipSpool(outputPath string) (*zipSpool, error) {
+	file, err := createSpool(outputPath)
+	if err != nil {
+		return nil, err
+	}
+	return &zipSpool{file: file, archive: zip.NewWriter(file)}, nil
+}
+
+func (z *zipSpool) add(name string, method uint16, data []byte) error {
+	return writeZipEntry(z.archive, name, method, data)
+}
+
+func (z *zipSpool) commit(outputPath string) error {
+	if err := z.archive.Close(); err != nil {
+		return fmt.Errorf("failed to finalize archive: %w", err)
+	}
+	return commitSpool(z.file, outputPath)
+}
+
+func (z *zipSpool) discard() {
+	if z != nil {
+		discardSpool(z.file)
+	}
+}
+
+// pageName numbers pages with a fixed width so they sort in reading order
+// without knowing the page count up front.
+func pageName(number int) string {
+	return fmt.Sprintf("%04d", number)
+}
@@ -0,0 +1,230 @@
+package exports
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// subsetTables are the TrueType tables kept in an embedded subset. The name
+// table and the glyph names in post are dropped, PDF readers do not need
+// them.
+var subsetTables = []string{"OS/2", "cmap", "cvt ", "fpgm", "glyf", "head", "hhea", "hmtx", "loca", "maxp", "post", "prep"}
+
+// Flags of a composite glyph component.
+const (
+	componentArgsAreWords  = 0x0001
+	componentHasScale      = 0x0008
+	componentMoreFollow    = 0x0020
+	componentHasXYScale    = 0x0040
+	componentHasTwoByTwo   = 0x0080
+	compositeGlyphContours = -1
+)
+
+var errBadTrueType = errors.New("malformed TrueType font")
+
+// subsetTrueType returns the font with the outlines of every glyph but the
+// ones in keep, and the components they are built from, left empty. Glyph
+// ids stay the same, so text written as glyph ids needs no remapping.
+func subsetTrueType(data []byte, keep map[uint16]bool) ([]byte, error) {
+	tables, err := trueTypeTables(data)
+	if err != nil {
+		return nil, err
+	}
+	head, maxp, loca, glyf := tables["head"], tables["maxp"], tables["loca"], tables["glyf"]
+	if len(head) < 54 || len(maxp) < 6 || glyf == nil {
+		return nil, errBadTrueType
+	}
+
+	numGlyphs := int(binary.BigEndian.Uint16(maxp[4:]))
+	offsets, err := glyphOffsets(loca, numGlyphs, binary.BigEndian.Uint16(head[50:]) == 1)
+	if err != nil {
+		return nil, err
+	}
+	glyph := func(id uint16) ([]byte, error) {
+		if int(id) >= numGlyphs || offsets[id] > offsets[id+1] || offsets[id+1] > len(glyf) {
+			return nil, errBadTrueType
+		}
+		return glyf[offsets[id]:offsets[id+1]], nil
+	}
+
+	// Composite glyphs pull in the glyphs they are built from.
+	keep = maps.Clone(keep)
+	keep[0] = true
+	for pending := slices.Collect(maps.Keys(keep)); len(pending) > 0; {
+		id := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		outline, err := glyph(id)
+		if err != nil {
+			return nil, err
+		}
+		components, err := glyphComponents(outline)
+		if err != nil {
+			return nil, err
+		}
+		for _, component := range components {
+			if !keep[component] {
+				keep[component] = true
+				pending = append(pending, component)
+			}
+		}
+	}
+
+	// The subset always uses long offsets, outlines are kept 4 byte aligned.
+	var newGlyf []byte
+	newLoca := make([]byte, 4*(numGlyphs+1))
+	for id := range numGlyphs {
+		binary.BigEndian.PutUint32(newLoca[4*id:], uint32(len(newGlyf)))
+		if keep[uint16(id)] {
+			outline, _ := glyph(uint16(id))
+			newGlyf = append(newGlyf, outline...)
+			newGlyf = append(newGlyf, make([]byte, -len(newGlyf)&3)...)
+		}
+	}
+	binary.BigEndian.PutUint32(newLoca[4*numGlyphs:], uint32(len(newGlyf)))
+
+	newHead := slices.Clone(head)
+	binary.BigEndian.PutUint32(newHead[8:], 0)
+	binary.BigEndian.PutUint16(newHead[50:], 1)
+	tables["head"], tables["loca"], tables["glyf"] = newHead, newLoca, newGlyf
+
+	// A version 3 post table has no glyph names.
+	if post := tables["post"]; len(post) >= 32 {
+		post = slices.Clone(post[:32])
+		binary.BigEndian.PutUint32(post, 0x00030000)
+		tables["post"] = post
+	}
+	return writeTrueType(tables)
+}
+
+// trueTypeTables reads the table directory of a TrueType font.
+func trueTypeTables(data []byte) (map[string][]byte, error) {
+	if len(data) < 12 || binary.BigEndian.Uint32(data) != 0x00010000 {
+		return nil, errBadTrueType
+	}
+	count := int(binary.BigEndian.Uint16(data[4:]))
+	if len(data) < 12+16*count {
+		return nil, errBadTrueType
+	}
+	tables := make(map[string][]byte, count)
+	for i := range count {
+		record := data[12+16*i:]
+		offset, length := int(binary.BigEndian.Uint32(record[8:])), int(binary.BigEndian.Uint32(record[12:]))
+		if offset+length > len(data) {
+			return nil, fmt.Errorf("%w: table %q runs past the end", errBadTrueType, record[:4])
+		}
+		tables[string(record[:4])] = data[offset : offset+length]
+	}
+	return tables, nil
+}
+
+// glyphOffsets reads the loca table into numGlyphs+1 offsets into glyf.
+func glyphOffsets(loca []byte, numGlyphs int, long bool) ([]int, error) {
+	offsets := make([]int, numGlyphs+1)
+	if long {
+		if len(loca) < 4*len(offsets) {
+			return nil, errBadTrueType
+		}
+		for i := range offsets {
+			offsets[i] = int(binary.BigEndian.Uint32(loca[4*i:]))
+		}
+		return offsets, nil
+	}
+	if len(loca) < 2*len(offsets) {
+		return nil, errBadTrueType
+	}
+	for i := range offsets {
+		offsets[i] = 2 * int(binary.BigEndian.Uint16(loca[2*i:]))
+	}
+	return offsets, nil
+}
+
+// glyphComponents lists the glyphs a composite glyph is built from, simple
+// and empty glyphs have none.
+func glyphComponents(outline []byte) ([]uint16, error) {
+	if len(outline) < 10 || int16(binary.BigEndian.Uint16(outline)) != compositeGlyphContours {
+		return nil, nil
+	}
+	var components []uint16
+	for rest := outline[10:]; ; {
+		if len(rest) < 4 {
+			return nil, errBadTrueType
+		}
+		flags := binary.BigEndian.Uint16(rest)
+		components = append(components, binary.BigEndian.Uint16(rest[2:]))
+
+		size := 4 + 2
+		if flags&componentArgsAreWords != 0 {
+			size += 2
+		}
+		switch {
+		case flags&componentHasScale != 0:
+			size += 2
+		case flags&componentHasXYScale != 0:
+			size += 4
+		case flags&componentHasTwoByTwo != 0:
+			size += 8
+		}
+		if len(rest) < size {
+			return nil, errBadTrueType
+		}
+		if flags&componentMoreFollow == 0 {
+			return components, nil
+		}
+		rest = rest[size:]
+	}
+}
+
+// writeTrueType lays out the subset tables as a font file and sets the
+// checksums the format asks for.
+func writeTrueType(tables map[string][]byte) ([]byte, error) {
+	var tags []string
+	for _, tag := range subsetTables {
+		if tables[tag] != nil {
+			tags = append(tags, tag)
+		}
+	}
+
+	searchRange, entrySelector := 1, 0
+	for searchRange*2 <= len(tags) {
+		searchRange, entrySelector = searchRange*2, entrySelector+1
+	}
+	font := make([]byte, 12+16*len(tags))
+	binary.BigEndian.PutUint32(font, 0x00010000)
+	binary.BigEndian.PutUint16(font[4:], uint16(len(tags)))
+	binary.BigEndian.PutUint16(font[6:], uint16(16*searchRange))
+	binary.BigEndian.PutUint16(font[8:], uint16(entrySelector))
+	binary.BigEndian.PutUint16(font[10:], uint16(16*(len(tags)-searchRange)))
+
+	headOffset := -1
+	for i, tag := range tags {
+		table := tables[tag]
+		if tag == "head" {
+			headOffset = len(font)
+		}
+		record := font[12+16*i:]
+		copy(record, tag)
+		binary.BigEndian.PutUint32(record[4:], trueTypeChecksum(table))
+		binary.BigEndian.PutUint32(record[8:], uint32(len(font)))
+		binary.BigEndian.PutUint32(record[12:], uint32(len(table)))
+		font = append(font, table...)
+		font = append(font, make([]byte, -len(font)&3)...)
+	}
+	if headOffset < 0 {
+		return nil, errBadTrueType
+	}
+	binary.BigEndian.PutUint32(font[headOffset+8:], 0xB1B0AFBA-trueTypeChecksum(font))
+	return font, nil
+}
+
+func trueTypeChecksum(data []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(data); i += 4 {
+		var word [4]byte
+		copy(word[:], data[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
@@ -0,0 +1,199 @@
+package exports
+
+import (
+	"encoding/binary"
+	"errors"
+	"slices"
+	"testing"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// glyphSegments loads one outline of f, nil when the glyph is empty.
+func glyphSegments(t *testing.T, f *sfnt.Font, glyph uint16) sfnt.Segments {
+	t.Helper()
+	var buf sfnt.Buffer
+	segments, err := f.LoadGlyph(&buf, sfnt.GlyphIndex(glyph), fixed.I(1000), nil)
+	if err != nil {
+		t.Fatalf("glyph %d does not load: %v", glyph, err)
+	}
+	return segments
+}
+
+func TestSubsetTrueType(t *testing.T) {
+	original, err := sfnt.Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := loadPDFFont()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keep := make(map[uint16]bool)
+	for _, r := range "Café" {
+		keep[f.glyphs[r]] = true
+	}
+	data, err := subsetTrueType(goregular.TTF, keep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) > len(goregular.TTF)/4 {
+		t.Errorf("subset is %d bytes of %d", len(data), len(goregular.TTF))
+	}
+	if sum := trueTypeChecksum(data); sum != 0xB1B0AFBA {
+		t.Errorf("font checksum is %#x, want 0xb1b0afba", sum)
+	}
+
+	subset, err := sfnt.Parse(data)
+	if err != nil {
+		t.Fatalf("subset does not parse: %v", err)
+	}
+	if subset.NumGlyphs() != original.NumGlyphs() {
+		t.Fatalf("subset has %d glyphs, want the ids of all %d kept", subset.NumGlyphs(), original.NumGlyphs())
+	}
+
+	var buf sfnt.Buffer
+	for glyph := range keep {
+		want, got := glyphSegments(t, original, glyph), glyphSegments(t, subset, glyph)
+		if len(got) == 0 || len(got) != len(want) {
+			t.Errorf("glyph %d has %d segments, want %d", glyph, len(got), len(want))
+		}
+		wantAdvance, _ := original.GlyphAdvance(&buf, sfnt.GlyphIndex(glyph), fixed.I(1000), font.HintingNone)
+		gotAdvance, _ := subset.GlyphAdvance(&buf, sfnt.GlyphIndex(glyph), fixed.I(1000), font.HintingNone)
+		if gotAdvance != wantAdvance {
+			t.Errorf("glyph %d advances %v, want %v", glyph, gotAdvance, wantAdvance)
+		}
+	}
+	for _, r := range "ZЖ" {
+		if segments := glyphSegments(t, subset, f.glyphs[r]); len(segments) != 0 {
+			t.Errorf("unused glyph for %q kept its outline", r)
+		}
+	}
+}
+
+func TestSubsetTrueTypeMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"not TrueType", []byte("OTTO\x00\x01\x00\x00\x00\x00\x00\x00")},
+		{"truncated directory", goregular.TTF[:40]},
+		{"truncated tables", goregular.TTF[:len(goregular.TTF)/2]},
+	}
+	for _, tt := range tests {
+		if _, err := subsetTrueType(tt.data, map[uint16]bool{1: true}); !errors.Is(err, errBadTrueType) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, errBadTrueType)
+		}
+	}
+}
+
+// compositeOutline builds a composite glyph from components given as flags
+// and glyph id.
+func compositeOutline(components ...[2]uint16) []byte {
+	outline := binary.BigEndian.AppendUint16(nil, 0xffff)
+	outline = append(outline, make([]byte, 8)...)
+	for i, component := range components {
+		flags := component[0]
+		if i < len(components)-1 {
+			flags |= componentMoreFollow
+		}
+		outline = binary.BigEndian.AppendUint16(outline, flags)
+		outline = binary.BigEndian.AppendUint16(outline, component[1])
+		size := 2
+		if flags&componentArgsAreWords != 0 {
+			size += 2
+		}
+		switch {
+		case flags&componentHasScale != 0:
+			size += 2
+		case flags&componentHasXYScale != 0:
+			size += 4
+		case flags&componentHasTwoByTwo != 0:
+			size += 8
+		}
+		outline = append(outline, make([]byte, size)...)
+	}
+	return outline
+}
+
+func TestGlyphComponents(t *testing.T) {
+	simple := binary.BigEndian.AppendUint16(nil, 2)
+	simple = append(simple, make([]byte, 20)...)
+	tests := []struct {
+		name    string
+		outline []byte
+		want    []uint16
+		wantErr bool
+	}{
+		{name: "empty glyph"},
+		{name: "simple glyph", outline: simple},
+		{name: "one component", outline: compositeOutline([2]uint16{0, 7}), want: []uint16{7}},
+		{
+			name: "every argument size",
+			outline: compositeOutline(
+				[2]uint16{componentArgsAreWords, 3},
+				[2]uint16{componentHasScale, 4},
+				[2]uint16{componentArgsAreWords | componentHasXYScale, 5},
+				[2]uint16{componentHasTwoByTwo, 6},
+			),
+			want: []uint16{3, 4, 5, 6},
+		},
+		{name: "truncated", outline: compositeOutline([2]uint16{componentHasTwoByTwo, 3})[:16], wantErr: true},
+		{name: "more promised", outline: compositeOutline([2]uint16{componentMoreFollow, 3}), wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := glyphComponents(tt.outline)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v", tt.name, err)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: components %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSubsetTrueTypeKeepsComponents(t *testing.T) {
+	// A font of four glyphs where glyph 3 is built from glyph 1.
+	component := binary.BigEndian.AppendUint16(nil, 1)
+	component = append(component, make([]byte, 18)...)
+	outlines := [][]byte{nil, component, component, compositeOutline([2]uint16{0, 1})}
+
+	var glyf []byte
+	loca := make([]byte, 4*(len(outlines)+1))
+	for i, outline := range outlines {
+		binary.BigEndian.PutUint32(loca[4*i:], uint32(len(glyf)))
+		glyf = append(glyf, outline...)
+	}
+	binary.BigEndian.PutUint32(loca[4*len(outlines):], uint32(len(glyf)))
+	head := make([]byte, 54)
+	binary.BigEndian.PutUint16(head[50:], 1)
+	maxp := make([]byte, 6)
+	binary.BigEndian.PutUint16(maxp[4:], uint16(len(outlines)))
+	data, err := writeTrueType(map[string][]byte{"glyf": glyf, "head": head, "loca": loca, "maxp": maxp})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err = subsetTrueType(data, map[uint16]bool{3: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tables, err := trueTypeTables(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	offsets, err := glyphOffsets(tables["loca"], len(outlines), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, kept := range []bool{false, true, false, true} {
+		if size := offsets[id+1] - offsets[id]; (size > 0) != kept {
+			t.Errorf("glyph %d is %d bytes, kept should be %v", id, size, kept)
+		}
+	}
+}